@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+
+	"github.com/nishanths/unusedargs/usages"
+)
+
+// An abstractFunc is a method declared in an interface type, or a
+// named func type. Its params are reported once every package has been
+// analysed, if they are unused by every implementation.
+type abstractFunc struct {
+	Key       string // typeKey of the type, and method name for an interface method
+	Package   string // package, as labelled in statistics
+	Position  token.Position
+	FuncName  string // Interface.Method, or the func type's name
+	FuncStart int    // byte offset of the start of the declaration's line
+	FuncEnd   int    // byte offset of the end of the declaration's line
+	Exported  bool
+	Params    []abstractParam
+}
+
+type abstractParam struct {
+	Name     string // name of the param, or #n if it is unnamed
+	Position token.Position
+}
+
+// A concreteFunc implements an abstractFunc: it is a method of a
+// concrete type implementing an interface method, or a function
+// converted or assigned to a named func type.
+type concreteFunc struct {
+	Key      string // of the abstractFunc
+	Position token.Position
+	Unused   []bool // whether each param is unused
+}
+
+// abstract holds the abstract funcs and their implementations of all
+// the reports handled since the last call to reportAbstract.
+var abstract struct {
+	Funcs []abstractFunc
+	Impls []concreteFunc
+}
+
+// namedTypes returns the non-generic named types declared at package
+// scope, in declaration order, and their package.
+func namedTypes(info *types.Info) ([]*types.Named, *types.Package) {
+	var pkg *types.Package
+	var named []*types.Named
+	for _, obj := range info.Defs {
+		tn, ok := obj.(*types.TypeName)
+		if !ok || tn.IsAlias() || tn.Parent() != tn.Pkg().Scope() {
+			continue
+		}
+		if t, ok := tn.Type().(*types.Named); ok && t.TypeParams().Len() == 0 {
+			named = append(named, t)
+			pkg = tn.Pkg()
+		}
+	}
+	sort.Slice(named, func(i, j int) bool { return named[i].Obj().Pos() < named[j].Obj().Pos() })
+	return named, pkg
+}
+
+// typeKey identifies a named type declared at package scope by its
+// package's import path and its name. path identifies the package
+// being checked, and is used for it and its external test package
+// if they were type checked without an import path.
+func typeKey(tn *types.TypeName, path string) string {
+	switch p := tn.Pkg(); {
+	case p.Path() != "":
+		path = p.Path()
+	case strings.HasSuffix(p.Name(), "_test"):
+		path += "_test"
+	}
+	return path + "." + tn.Name()
+}
+
+// usedObjects returns the objects with uses in the package.
+func usedObjects(info *types.Info) map[types.Object]bool {
+	used := make(map[types.Object]bool)
+	for _, obj := range info.Uses {
+		used[obj] = true
+	}
+	return used
+}
+
+// newAbstractFunc returns the abstractFunc for the signature declared
+// at pos.
+func newAbstractFunc(key, label, name string, exported bool, sig *types.Signature, fset *token.FileSet, pos token.Position, src []byte) abstractFunc {
+	start := bytes.LastIndexByte(src[:pos.Offset], '\n') + 1
+	end := bytes.IndexByte(src[pos.Offset:], '\n')
+	if end < 0 {
+		end = len(src)
+	} else {
+		end += pos.Offset
+	}
+	a := abstractFunc{
+		Key:       key,
+		Package:   label,
+		Position:  pos,
+		FuncName:  name,
+		FuncStart: start,
+		FuncEnd:   end,
+		Exported:  exported,
+	}
+	params := sig.Params()
+	for i := 0; i < params.Len(); i++ {
+		p := abstractParam{Name: params.At(i).Name(), Position: fset.Position(params.At(i).Pos())}
+		if p.Name == "" || p.Name == "_" {
+			p.Name = fmt.Sprintf("#%d", i+1)
+		}
+		a.Params = append(a.Params, p)
+	}
+	return a
+}
+
+// unusedParams reports whether each of the params of sig is unused.
+func unusedParams(sig *types.Signature, used map[types.Object]bool) []bool {
+	unused := make([]bool, sig.Params().Len())
+	for i := range unused {
+		p := sig.Params().At(i)
+		unused[i] = p.Name() == "" || p.Name() == "_" || !used[p]
+	}
+	return unused
+}
+
+// reportAbstract handles the findings for the params of the recorded
+// abstract funcs that are unused by every recorded implementation, and
+// clears the records.
+func reportAbstract() {
+	impls := make(map[string][]concreteFunc)
+	seen := make(map[string]bool)
+	for _, impl := range abstract.Impls {
+		k := impl.Key + " " + impl.Position.String()
+		if seen[k] {
+			continue // same implementation, via another type or configuration
+		}
+		seen[k] = true
+		impls[impl.Key] = append(impls[impl.Key], impl)
+	}
+
+	rep := &report{}
+	done := make(map[string]bool)
+	for _, m := range abstract.Funcs {
+		if done[m.Key] || len(impls[m.Key]) == 0 {
+			continue
+		}
+		done[m.Key] = true
+		for i, p := range m.Params {
+			unused := true
+			for _, impl := range impls[m.Key] {
+				if i >= len(impl.Unused) || !impl.Unused[i] {
+					unused = false
+					break
+				}
+			}
+			if !unused {
+				continue
+			}
+			rep.Findings = append(rep.Findings, finding{
+				Package:      m.Package,
+				Position:     p.Position,
+				FuncPosition: m.Position,
+				FuncName:     m.FuncName,
+				FuncStart:    m.FuncStart,
+				FuncEnd:      m.FuncEnd,
+				Kind:         usages.FuncParam,
+				Name:         p.Name,
+				Exported:     m.Exported,
+				Impls:        len(impls[m.Key]),
+			})
+		}
+	}
+	sort.SliceStable(rep.Findings, func(i, j int) bool {
+		a, b := rep.Findings[i].Position, rep.Findings[j].Position
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		return a.Offset < b.Offset
+	})
+	abstract.Funcs, abstract.Impls = nil, nil
+	handleReport(rep)
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go/build"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// cacheVersion is part of every cache key. Bump it when the format of
+// report or the analysis performed by analyse changes.
+const cacheVersion = "27"
+
+// cache is the on-disk cache of package reports. The zero value is a
+// disabled cache.
+var cache resultCache
+
+// A resultCache stores reports in a directory, one file per key.
+// Reading or writing the cache is best effort; failures are treated
+// as cache misses.
+type resultCache struct {
+	dir string // empty if the cache is disabled
+}
+
+// openCache returns the cache in the user's cache directory. The
+// returned cache is disabled if there is no such directory.
+func openCache() resultCache {
+	dir, err := cacheDir()
+	if err != nil {
+		return resultCache{}
+	}
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return resultCache{}
+	}
+	return resultCache{dir: dir}
+}
+
+func cacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "unusedargs"), nil
+}
+
+// get returns the report stored for key, or nil if there is none.
+func (c resultCache) get(key string) *report {
+	if c.dir == "" {
+		return nil
+	}
+	b, err := ioutil.ReadFile(filepath.Join(c.dir, key))
+	if err != nil {
+		return nil
+	}
+	var rep report
+	if err := json.Unmarshal(b, &rep); err != nil {
+		return nil
+	}
+	return &rep
+}
+
+// put stores the report for key.
+func (c resultCache) put(key string, rep *report) {
+	if c.dir == "" {
+		return
+	}
+	b, err := json.Marshal(rep)
+	if err != nil {
+		return
+	}
+	// Write to a temporary file and rename, so that concurrent runs
+	// never observe a partially written entry.
+	f, err := ioutil.TempFile(c.dir, "tmp-")
+	if err != nil {
+		return
+	}
+	_, err = f.Write(b)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return
+	}
+	if err := os.Rename(f.Name(), filepath.Join(c.dir, key)); err != nil {
+		os.Remove(f.Name())
+	}
+}
+
+// clean removes all entries from the cache directory, even if the
+// cache is disabled for this run.
+func (c resultCache) clean() error {
+	dir := c.dir
+	if dir == "" {
+		var err error
+		if dir, err = cacheDir(); err != nil {
+			return err
+		}
+	}
+	return os.RemoveAll(dir)
+}
+
+// cacheKey returns the cache key for the package with the given
+// source file contents, as imported using ctx. The key covers the
+// contents, the build configuration, the go.mod and go.sum files of
+// the package's module, and the sources of its dependencies.
+func cacheKey(ctx *build.Context, pkg *build.Package, contents map[string][]byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "unusedargs %s %s\n", cacheVersion, runtime.Version())
+	fmt.Fprintf(h, "strict %t tests %t importer %s interfaces %t functypes %t deadcode %t narrow %t fields %g chandir %t pointers %t\n",
+		strict, tests, importerName, checkInterfaces, checkFuncTypes, checkDeadCode, checkNarrow, fieldsFraction, checkChanDir, checkPointers)
+	if checkLogging {
+		fmt.Fprintf(h, "logging %q\n", extraLogFuncs)
+	}
+	fmt.Fprintf(h, "context %s %s %s %t %q %q\n", ctx.GOOS, ctx.GOARCH,
+		ctx.Compiler, ctx.CgoEnabled, ctx.BuildTags, ctx.ReleaseTags)
+
+	var names []string
+	for name := range contents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(h, "file %s %d\n", name, len(contents[name]))
+		h.Write(contents[name])
+	}
+
+	if root := moduleRoot(pkg.Dir); root != "" {
+		for _, name := range []string{"go.mod", "go.sum"} {
+			b, _ := ioutil.ReadFile(filepath.Join(root, name))
+			fmt.Fprintf(h, "%s %d\n", name, len(b))
+			h.Write(b)
+		}
+	}
+
+	for _, path := range imports(pkg) {
+		fmt.Fprintf(h, "import %s %s\n", path, depID(ctx, path, pkg.Dir, make(map[string]bool)))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// imports returns the sorted import paths of the package, including
+// the imports of its test files.
+func imports(pkg *build.Package) []string {
+	seen := make(map[string]bool)
+	var paths []string
+	for _, list := range [][]string{pkg.Imports, pkg.TestImports, pkg.XTestImports} {
+		for _, path := range list {
+			if !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+			}
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// moduleRoot returns the directory of the go.mod file of the module
+// containing dir, or the empty string if there is none.
+func moduleRoot(dir string) string {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for d := abs; ; d = filepath.Dir(d) {
+		if _, err := os.Stat(filepath.Join(d, "go.mod")); err == nil {
+			return d
+		}
+		if filepath.Dir(d) == d {
+			return ""
+		}
+	}
+}
+
+// depIDs memoizes depID for the current run; hashing the sources of a
+// package and its dependencies can be expensive. It is reset by
+// resetDepIDs.
+var depIDs sync.Map // map[[4]string]string
+
+// resetDepIDs forgets the memoized dependency ids, so that the next run
+// sees changes to the sources of dependencies.
+func resetDepIDs() {
+	depIDs.Range(func(k, _ interface{}) bool {
+		depIDs.Delete(k)
+		return true
+	})
+}
+
+// depID identifies the current sources of the package with the import
+// path, as imported from srcDir using ctx, and of the packages it
+// imports. Packages in GOROOT are identified by the Go version, which
+// is part of every cache key. visiting holds the packages whose ids
+// are being computed, to stop at import cycles.
+func depID(ctx *build.Context, path, srcDir string, visiting map[string]bool) string {
+	if path == "C" || path == "unsafe" {
+		return path
+	}
+	k := [4]string{path, srcDir, ctx.GOOS + "/" + ctx.GOARCH, fmt.Sprint(ctx.BuildTags)}
+	if id, ok := depIDs.Load(k); ok {
+		return id.(string)
+	}
+	dep, err := importFromDisk(ctx, path, srcDir)
+	switch {
+	case err != nil:
+		return "missing"
+	case dep.Goroot:
+		return "goroot"
+	case visiting[dep.Dir]:
+		return "cycle"
+	}
+	visiting[dep.Dir] = true
+	defer delete(visiting, dep.Dir)
+
+	h := sha256.New()
+	for _, name := range append(append([]string(nil), dep.GoFiles...), dep.CgoFiles...) {
+		b, err := ioutil.ReadFile(filepath.Join(dep.Dir, name))
+		if err != nil {
+			return "missing"
+		}
+		fmt.Fprintf(h, "file %s %d\n", name, len(b))
+		h.Write(b)
+	}
+	for _, imp := range dep.Imports {
+		fmt.Fprintf(h, "import %s %s\n", imp, depID(ctx, imp, dep.Dir, visiting))
+	}
+	id := hex.EncodeToString(h.Sum(nil))
+	depIDs.Store(k, id)
+	return id
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+// checkChanDir is set by -chandir. If set, params of bidirectional
+// channel type that the function only sends on, or only receives from,
+// are reported, with the narrower channel type.
+var checkChanDir bool
+
+// fixChanDir is set by -fix. If set, the signatures of the functions
+// reported by -chandir are rewritten to the narrower channel types.
+var fixChanDir bool
+
+// chanFixes are the byte offsets, for each file, at which to insert
+// "<-" to narrow channel params, if -fix is set.
+var chanFixes = make(map[string]map[int]bool)
+
+// narrowerChan returns the type, as written in the param's package, of
+// the channel the param could be declared as if the function only sends
+// on it, or only receives from it, and the position at which to insert
+// "<-" in the signature to narrow it. A use passing the param to a
+// function is a send or a receive if the function takes a channel of
+// that direction; comparing it to nil, len, and cap are neither. It
+// returns the empty string if the param isn't a bidirectional channel,
+// or has any other use. The position is token.NoPos if the signature
+// can't be rewritten, such as when the param shares its type with
+// others.
+func narrowerChan(fn ast.Node, id *ast.Ident, uses []*ast.Ident, info *types.Info) (string, token.Pos) {
+	obj := info.Defs[id]
+	if obj == nil || len(uses) == 0 {
+		return "", token.NoPos
+	}
+	ch, ok := obj.Type().(*types.Chan)
+	if !ok || ch.Dir() != types.SendRecv {
+		return "", token.NoPos
+	}
+
+	isUse := make(map[*ast.Ident]bool)
+	for _, u := range uses {
+		isUse[u] = true
+	}
+	var send, recv, other bool
+	var stack []ast.Node
+	ast.Inspect(fn, func(n ast.Node) bool {
+		if n == nil {
+			stack = stack[:len(stack)-1]
+			return true
+		}
+		stack = append(stack, n)
+		u, ok := n.(*ast.Ident)
+		if !ok || !isUse[u] {
+			return true
+		}
+
+		// Find the use's parent, outside any parentheses.
+		var c ast.Expr = u
+		i := len(stack) - 2
+		for ; i >= 0; i-- {
+			p, ok := stack[i].(*ast.ParenExpr)
+			if !ok {
+				break
+			}
+			c = p
+		}
+		if i < 0 {
+			other = true
+			return true
+		}
+
+		switch p := stack[i].(type) {
+		case *ast.SendStmt:
+			if p.Chan == c {
+				send = true
+			} else {
+				other = true // sent on another channel
+			}
+		case *ast.UnaryExpr:
+			if p.Op == token.ARROW {
+				recv = true
+			} else {
+				other = true
+			}
+		case *ast.RangeStmt:
+			if p.X == c {
+				recv = true
+			} else {
+				other = true
+			}
+		case *ast.BinaryExpr:
+			y := p.X
+			if y == c {
+				y = p.Y
+			}
+			if !info.Types[y].IsNil() {
+				other = true
+			}
+		case *ast.CallExpr:
+			if p.Fun == c {
+				other = true
+				break
+			}
+			if fid, ok := ast.Unparen(p.Fun).(*ast.Ident); ok {
+				if b, ok := info.Uses[fid].(*types.Builtin); ok {
+					switch b.Name() {
+					case "close":
+						send = true
+					case "len", "cap":
+					default:
+						other = true
+					}
+					break
+				}
+			}
+			sig, ok := info.Types[p.Fun].Type.(*types.Signature)
+			if !ok || p.Ellipsis.IsValid() {
+				other = true
+				break
+			}
+			var t types.Type
+			for j, arg := range p.Args {
+				if arg != c {
+					continue
+				}
+				switch params := sig.Params(); {
+				case sig.Variadic() && j >= params.Len()-1:
+					t = params.At(params.Len() - 1).Type().(*types.Slice).Elem()
+				case j < params.Len():
+					t = params.At(j).Type()
+				}
+			}
+			pch, _ := t.(*types.Chan)
+			switch {
+			case pch == nil:
+				other = true
+			case pch.Dir() == types.SendOnly:
+				send = true
+			case pch.Dir() == types.RecvOnly:
+				recv = true
+			default:
+				other = true // passed on as bidirectional
+			}
+		default:
+			other = true
+		}
+		return true
+	})
+	if other || send == recv {
+		return "", token.NoPos
+	}
+
+	dir := types.SendOnly
+	if recv {
+		dir = types.RecvOnly
+	}
+	typ := types.TypeString(types.NewChan(dir, ch.Elem()), types.RelativeTo(obj.Pkg()))
+
+	// Find the param's type in the signature.
+	var ftype *ast.FuncType
+	switch fn := fn.(type) {
+	case *ast.FuncDecl:
+		ftype = fn.Type
+	case *ast.FuncLit:
+		ftype = fn.Type
+	}
+	pos := token.NoPos
+	if ftype != nil && ftype.Params != nil {
+		for _, field := range ftype.Params.List {
+			ct, ok := field.Type.(*ast.ChanType)
+			if !ok || len(field.Names) != 1 || field.Names[0] != id || ct.Dir != ast.SEND|ast.RECV {
+				continue
+			}
+			pos = ct.Begin // before "chan", for <-chan T
+			if send {
+				pos += token.Pos(len("chan")) // after "chan", for chan<- T
+			}
+		}
+	}
+	return typ, pos
+}
+
+// addChanFix records the fix for a finding with a narrower channel
+// type, if -fix is set.
+func addChanFix(f finding) {
+	if !fixChanDir || f.ChanFix <= 0 {
+		return
+	}
+	name := f.Position.Filename
+	if chanFixes[name] == nil {
+		chanFixes[name] = make(map[int]bool)
+	}
+	chanFixes[name][f.ChanFix] = true
+}
+
+// applyChanFixes rewrites the files with recorded fixes. A fix is
+// skipped if the file no longer has a channel type at its offset.
+func applyChanFixes() error {
+	var names []string
+	for name := range chanFixes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		src, err := ioutil.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		var offsets []int
+		for off := range chanFixes[name] {
+			offsets = append(offsets, off)
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(offsets)))
+
+		changed := false
+		for _, off := range offsets {
+			if off > len(src) {
+				continue
+			}
+			if !bytes.HasPrefix(src[off:], []byte("chan")) && !bytes.HasSuffix(src[:off], []byte("chan")) {
+				continue // file changed since it was analysed
+			}
+			src = append(src[:off], append([]byte("<-"), src[off:]...)...)
+			changed = true
+		}
+		if !changed {
+			continue
+		}
+		fi, err := os.Stat(name)
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(name, src, fi.Mode().Perm()); err != nil {
+			return fmt.Errorf("fixing %s: %v", name, err)
+		}
+	}
+	chanFixes = make(map[string]map[int]bool)
+	return nil
+}
+
+// chanUse describes how a param of the narrower channel type t is used.
+func chanUse(t string) string {
+	if strings.HasPrefix(t, "<-") {
+		return "received from"
+	}
+	return "sent on"
+}
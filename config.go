@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"go/build"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// configs are the build configurations supplied with -config.
+var configs configList
+
+// A config is a build configuration to analyse packages in.
+type config struct {
+	name string // as given on the command line
+	ctx  build.Context
+}
+
+// configList is a flag.Value for repeated -config flags.
+type configList []config
+
+func (l *configList) String() string {
+	var names []string
+	for _, c := range *l {
+		names = append(names, c.name)
+	}
+	return strings.Join(names, " ")
+}
+
+// Set parses a configuration of the form [GOOS/GOARCH][,tag...].
+func (l *configList) Set(s string) error {
+	ctx := buildContext
+	parts := strings.Split(s, ",")
+	if strings.Contains(parts[0], "/") {
+		platform := strings.Split(parts[0], "/")
+		if len(platform) != 2 || platform[0] == "" || platform[1] == "" {
+			return fmt.Errorf("bad platform %q, want GOOS/GOARCH", parts[0])
+		}
+		ctx.GOOS, ctx.GOARCH = platform[0], platform[1]
+		if ctx.GOOS != buildContext.GOOS || ctx.GOARCH != buildContext.GOARCH {
+			ctx.CgoEnabled = false // cross-compiling
+		}
+		parts = parts[1:]
+	}
+	ctx.BuildTags = append([]string(nil), buildContext.BuildTags...)
+	for _, tag := range parts {
+		if tag == "" {
+			return fmt.Errorf("empty build tag in %q", s)
+		}
+		ctx.BuildTags = append(ctx.BuildTags, tag)
+	}
+	*l = append(*l, config{name: s, ctx: ctx})
+	return nil
+}
+
+// forConfigs returns a task that runs check in each configuration
+// in configs and merges the reports, or in buildContext if there are
+// no configs.
+func forConfigs(check func(*build.Context) *report) task {
+	if len(configs) == 0 {
+		return func() *report { return check(&buildContext) }
+	}
+	return func() *report {
+		reps := make([]*report, len(configs))
+		for i := range configs {
+			reps[i] = check(&configs[i].ctx)
+		}
+		return mergeReports(configs, reps)
+	}
+}
+
+// mergeReports merges the reports of the same target produced in each
+// of the configurations. A finding is reported if it is made in every
+// configuration whose files include its function. Packages and inputs
+// analysed in several configurations are counted once in statistics.
+func mergeReports(configs []config, reps []*report) *report {
+	merged := &report{}
+	skipped := make(map[string]bool)
+	partial := make(map[string]bool)
+	noDead := make(map[string]bool)
+
+	// A finding is identified by its position and what it says.
+	type key struct {
+		filename string
+		offset   int
+		message  string
+	}
+	keyOf := func(f finding) key {
+		return key{f.Position.Filename, f.Position.Offset, f.message()}
+	}
+	unusedIn := make(map[key][]string) // configurations where the finding is made
+	type inputKey struct {
+		pos  token.Position
+		kind string
+	}
+	pkgIndex := make(map[string]int)     // index of each package's stats
+	inputIndex := make(map[inputKey]int) // index of each input
+	files := make(map[string][]string)   // configurations that include a file
+	var order []finding
+
+	for i, rep := range reps {
+		if rep.Err != nil {
+			return &report{Err: fmt.Errorf("%s: %v", configs[i].name, rep.Err)}
+		}
+		for _, name := range rep.Files {
+			files[name] = append(files[name], configs[i].name)
+		}
+		for _, s := range rep.Skipped {
+			skipped[s] = true
+		}
+		for _, pkg := range rep.Partial {
+			partial[pkg+" ("+configs[i].name+")"] = true
+		}
+		for _, pkg := range rep.NoDead {
+			noDead[pkg+" ("+configs[i].name+")"] = true
+		}
+		for _, st := range rep.Stats {
+			if j, ok := pkgIndex[st.Package]; ok {
+				merged.Stats[j].Failed = merged.Stats[j].Failed || st.Failed
+				continue
+			}
+			pkgIndex[st.Package] = len(merged.Stats)
+			merged.Stats = append(merged.Stats, st)
+		}
+		for _, in := range rep.Inputs {
+			k := inputKey{in.Position, in.Kind}
+			if j, ok := inputIndex[k]; ok {
+				merged.Inputs[j].Suppressed = merged.Inputs[j].Suppressed || in.Suppressed
+				merged.Inputs[j].Generated = merged.Inputs[j].Generated || in.Generated
+				continue
+			}
+			inputIndex[k] = len(merged.Inputs)
+			merged.Inputs = append(merged.Inputs, in)
+		}
+		// Abstract funcs and implementations are deduplicated when
+		// they are reported.
+		merged.Abstract = append(merged.Abstract, rep.Abstract...)
+		merged.Impls = append(merged.Impls, rep.Impls...)
+		for _, f := range rep.Findings {
+			k := keyOf(f)
+			if unusedIn[k] == nil {
+				order = append(order, f)
+			}
+			unusedIn[k] = append(unusedIn[k], configs[i].name)
+		}
+	}
+
+	for _, f := range order {
+		k := keyOf(f)
+		if len(unusedIn[k]) != len(files[f.Position.Filename]) {
+			continue // not made in some configuration
+		}
+		f.Configs = unusedIn[k]
+		merged.Findings = append(merged.Findings, f)
+	}
+	sort.SliceStable(merged.Findings, func(i, j int) bool {
+		a, b := keyOf(merged.Findings[i]), keyOf(merged.Findings[j])
+		if a.filename != b.filename {
+			return a.filename < b.filename
+		}
+		if a.offset != b.offset {
+			return a.offset < b.offset
+		}
+		return a.message < b.message
+	})
+
+	for name := range files {
+		merged.Files = append(merged.Files, name)
+	}
+	sort.Strings(merged.Files)
+	merged.Skipped = sortedSet(skipped)
+	merged.Partial = sortedSet(partial)
+	merged.NoDead = sortedSet(noDead)
+	return merged
+}
+
+// sortedSet returns the members of the set, sorted, or nil if it is
+// empty.
+func sortedSet(set map[string]bool) []string {
+	var list []string
+	for s := range set {
+		list = append(list, s)
+	}
+	sort.Strings(list)
+	return list
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+// kindContext is the finding kind of an unused context.Context param.
+// An unused context often means the function doesn't propagate
+// cancellation to the calls it makes.
+const kindContext = "context"
+
+// A freshContext is a call to context.Background or context.TODO
+// passed as an argument, where an unused context could be forwarded.
+type freshContext struct {
+	Call     string // context.Background() or context.TODO()
+	Position token.Position
+}
+
+// isContext reports whether t is context.Context.
+func isContext(t types.Type) bool {
+	n, ok := t.(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := n.Obj()
+	return obj.Pkg() != nil && obj.Pkg().Path() == "context" && obj.Name() == "Context"
+}
+
+// freshContexts returns the calls to context.Background and
+// context.TODO in the function's body that are passed as arguments.
+func freshContexts(fn ast.Node, info *types.Info, fset *token.FileSet) []freshContext {
+	var fresh []freshContext
+	ast.Inspect(fn, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		for _, arg := range call.Args {
+			c, ok := ast.Unparen(arg).(*ast.CallExpr)
+			if !ok || len(c.Args) != 0 {
+				continue
+			}
+			sel, ok := c.Fun.(*ast.SelectorExpr)
+			if !ok {
+				continue
+			}
+			f, ok := info.Uses[sel.Sel].(*types.Func)
+			if !ok || f.Pkg() == nil || f.Pkg().Path() != "context" || (f.Name() != "Background" && f.Name() != "TODO") {
+				continue
+			}
+			fresh = append(fresh, freshContext{
+				Call:     "context." + f.Name() + "()",
+				Position: fset.Position(c.Pos()),
+			})
+		}
+		return true
+	})
+	return fresh
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"go/ast"
+	"go/types"
+	"sort"
+)
+
+// fieldsFraction is set by -fields. If positive, params of struct or
+// pointer to struct type are reported when the function only uses at
+// most this fraction of the struct's fields.
+var fieldsFraction float64
+
+// minFields is the number of fields below which a struct isn't reported
+// by -fields, since using a few of a few fields is normal.
+const minFields = 4
+
+// usedFields returns the names of the fields of the param's struct type
+// that are used in the function, in declaration order, and the number
+// of fields of the struct, if the function uses at most fieldsFraction
+// of them. It returns nil if the param isn't a struct or pointer to
+// struct, or has a use other than selecting a field.
+func usedFields(fn ast.Node, obj types.Object, uses []*ast.Ident, info *types.Info) ([]string, int) {
+	if obj == nil || len(uses) == 0 {
+		return nil, 0
+	}
+	t := obj.Type()
+	if p, ok := t.Underlying().(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	st, ok := t.Underlying().(*types.Struct)
+	if !ok || st.NumFields() < minFields {
+		return nil, 0
+	}
+
+	// Find the fields selected on the uses.
+	selected := make(map[*ast.Ident]int)
+	ast.Inspect(fn, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if x, ok := ast.Unparen(sel.X).(*ast.Ident); ok {
+			if s := info.Selections[sel]; s != nil && s.Kind() == types.FieldVal {
+				selected[x] = s.Index()[0] // the embedded field, for a promoted field
+			}
+		}
+		return true
+	})
+	used := make(map[int]bool)
+	for _, u := range uses {
+		i, ok := selected[u]
+		if !ok {
+			return nil, 0 // used other than to select a field
+		}
+		used[i] = true
+	}
+	if float64(len(used)) > fieldsFraction*float64(st.NumFields()) {
+		return nil, 0
+	}
+
+	var indexes []int
+	for i := range used {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	var names []string
+	for _, i := range indexes {
+		names = append(names, st.Field(i).Name())
+	}
+	return names, st.NumFields()
+}
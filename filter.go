@@ -0,0 +1,63 @@
+package main
+
+import "go/ast"
+
+// matchesFilters reports whether the finding should be reported,
+// given the -kind, -visibility, -staged, and -stdin flags.
+func matchesFilters(f finding) bool {
+	if kindFilter != "" && f.Kind != kindFilter {
+		return false
+	}
+	if reportOnly != nil && !reportOnly[absPath(f.Position.Filename)] {
+		return false
+	}
+	switch visibilityFilter {
+	case "exported":
+		return f.Exported
+	case "unexported":
+		return !f.Exported
+	}
+	return true
+}
+
+// isExported reports whether the function is part of its package's
+// exported API: a function with an exported name, or a method with an
+// exported name on a type with an exported name. Function literals are
+// never exported.
+func isExported(fn ast.Node) bool {
+	decl, ok := fn.(*ast.FuncDecl)
+	if !ok || !decl.Name.IsExported() {
+		return false
+	}
+	if decl.Recv == nil || len(decl.Recv.List) == 0 {
+		return true
+	}
+	name := recvTypeName(decl)
+	return name != "" && ast.IsExported(name)
+}
+
+// recvTypeName returns the name of the method's receiver type, without
+// any pointer or type parameters, or the empty string if decl is not a
+// method.
+func recvTypeName(decl *ast.FuncDecl) string {
+	if decl.Recv == nil || len(decl.Recv.List) == 0 {
+		return ""
+	}
+	typ := decl.Recv.List[0].Type
+	for {
+		switch t := typ.(type) {
+		case *ast.StarExpr:
+			typ = t.X
+		case *ast.ParenExpr:
+			typ = t.X
+		case *ast.IndexExpr: // generic receiver T[P]
+			typ = t.X
+		case *ast.IndexListExpr: // generic receiver T[P, Q]
+			typ = t.X
+		case *ast.Ident:
+			return t.Name
+		default:
+			return ""
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+)
+
+// checkFuncTypes is set by -functypes.
+var checkFuncTypes bool
+
+// recordFuncTypes records in rep the named func types declared in the
+// package, and the functions in the package converted or assigned to
+// named func types: by conversion, assignment, variable declaration,
+// call argument, return, composite literal element, or channel send.
+// A function value whose params can't be determined, such as a variable
+// or a function from another package, is recorded as using all of its
+// params. path identifies the package, as for typeKey.
+func recordFuncTypes(rep *report, fset *token.FileSet, info *types.Info, label, path string, contents map[string][]byte) {
+	named, pkg := namedTypes(info)
+	if pkg == nil {
+		return
+	}
+	used := usedObjects(info)
+
+	for _, t := range named {
+		if sig, ok := t.Underlying().(*types.Signature); ok {
+			pos := fset.Position(t.Obj().Pos())
+			rep.Abstract = append(rep.Abstract, newAbstractFunc(typeKey(t.Obj(), path), label, t.Obj().Name(),
+				t.Obj().Exported(), sig, fset, pos, contents[pos.Filename]))
+		}
+	}
+
+	// add records e, which is assigned to a value of type target.
+	add := func(target types.Type, e ast.Expr) {
+		t, ok := target.(*types.Named)
+		if !ok || t.TypeParams().Len() > 0 {
+			return
+		}
+		sig, ok := t.Underlying().(*types.Signature)
+		if !ok {
+			return
+		}
+		e = ast.Unparen(e)
+		tv, ok := info.Types[e]
+		if !ok || tv.IsNil() || types.Identical(tv.Type, t) {
+			return // not a function newly given the type
+		}
+
+		impl := concreteFunc{Key: typeKey(t.Obj(), path), Position: fset.Position(e.Pos()), Unused: make([]bool, sig.Params().Len())}
+		var fsig *types.Signature
+		var obj types.Object
+		switch e := e.(type) {
+		case *ast.FuncLit:
+			fsig, _ = tv.Type.(*types.Signature)
+		case *ast.Ident:
+			obj = info.Uses[e]
+		case *ast.SelectorExpr:
+			obj = info.Uses[e.Sel]
+		}
+		if fn, ok := obj.(*types.Func); ok && fn.Pkg() == pkg {
+			fsig = fn.Type().(*types.Signature)
+			impl.Position = fset.Position(fn.Pos())
+		}
+		if fsig != nil && fsig.Params().Len() == len(impl.Unused) {
+			impl.Unused = unusedParams(fsig, used)
+		}
+		rep.Impls = append(rep.Impls, impl)
+	}
+	typeOf := func(e ast.Expr) types.Type {
+		if tv, ok := info.Types[e]; ok {
+			return tv.Type
+		}
+		if id, ok := e.(*ast.Ident); ok && info.Uses[id] != nil {
+			return info.Uses[id].Type()
+		}
+		return nil
+	}
+
+	var files []*ast.File
+	for n := range info.Scopes {
+		if f, ok := n.(*ast.File); ok {
+			files = append(files, f)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Pos() < files[j].Pos() })
+
+	for _, f := range files {
+		var stack []ast.Node
+		ast.Inspect(f, func(n ast.Node) bool {
+			if n == nil {
+				stack = stack[:len(stack)-1]
+				return true
+			}
+			stack = append(stack, n)
+
+			switch n := n.(type) {
+			case *ast.CallExpr:
+				tv := info.Types[n.Fun]
+				if tv.IsType() {
+					if len(n.Args) == 1 {
+						add(tv.Type, n.Args[0])
+					}
+					break
+				}
+				t := typeOf(n.Fun)
+				if t == nil {
+					break
+				}
+				sig, ok := t.Underlying().(*types.Signature)
+				if !ok {
+					break
+				}
+				params := sig.Params()
+				for i, arg := range n.Args {
+					switch {
+					case sig.Variadic() && i >= params.Len()-1:
+						if s, ok := params.At(params.Len() - 1).Type().(*types.Slice); ok && !n.Ellipsis.IsValid() {
+							add(s.Elem(), arg)
+						}
+					case i < params.Len():
+						add(params.At(i).Type(), arg)
+					}
+				}
+			case *ast.AssignStmt:
+				if n.Tok == token.ASSIGN && len(n.Lhs) == len(n.Rhs) {
+					for i := range n.Lhs {
+						add(typeOf(n.Lhs[i]), n.Rhs[i])
+					}
+				}
+			case *ast.ValueSpec:
+				if n.Type != nil {
+					for _, v := range n.Values {
+						add(typeOf(n.Type), v)
+					}
+				}
+			case *ast.ReturnStmt:
+				var sig *types.Signature
+				for i := len(stack) - 1; i >= 0 && sig == nil; i-- {
+					switch fn := stack[i].(type) {
+					case *ast.FuncDecl:
+						if obj := info.Defs[fn.Name]; obj != nil {
+							sig, _ = obj.Type().(*types.Signature)
+						}
+					case *ast.FuncLit:
+						sig, _ = typeOf(fn).(*types.Signature)
+					}
+				}
+				if sig != nil && sig.Results().Len() == len(n.Results) {
+					for i, r := range n.Results {
+						add(sig.Results().At(i).Type(), r)
+					}
+				}
+			case *ast.SendStmt:
+				if t := typeOf(n.Chan); t != nil {
+					if ch, ok := t.Underlying().(*types.Chan); ok {
+						add(ch.Elem(), n.Value)
+					}
+				}
+			case *ast.CompositeLit:
+				t := typeOf(n)
+				if t == nil {
+					break
+				}
+				if p, ok := t.Underlying().(*types.Pointer); ok {
+					t = p.Elem()
+				}
+				for i, elt := range n.Elts {
+					kv, _ := elt.(*ast.KeyValueExpr)
+					switch u := t.Underlying().(type) {
+					case *types.Slice:
+						if kv != nil {
+							elt = kv.Value
+						}
+						add(u.Elem(), elt)
+					case *types.Array:
+						if kv != nil {
+							elt = kv.Value
+						}
+						add(u.Elem(), elt)
+					case *types.Map:
+						if kv != nil {
+							add(u.Key(), kv.Key)
+							add(u.Elem(), kv.Value)
+						}
+					case *types.Struct:
+						if kv == nil {
+							if i < u.NumFields() {
+								add(u.Field(i).Type(), elt)
+							}
+							break
+						}
+						if id, ok := kv.Key.(*ast.Ident); ok {
+							for j := 0; j < u.NumFields(); j++ {
+								if u.Field(j).Name() == id.Name {
+									add(u.Field(j).Type(), kv.Value)
+								}
+							}
+						}
+					}
+				}
+			}
+			return true
+		})
+	}
+}
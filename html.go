@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+)
+
+// htmlFunc is a function with unused receivers or params, as shown in
+// the HTML report.
+type htmlFunc struct {
+	Findings []finding
+	Lines    []htmlLine
+	Others   []inputUses
+}
+
+type htmlLine struct {
+	Num  int
+	Segs []htmlSeg
+}
+
+// htmlSeg is a piece of source text. Class is "unused" for an unused
+// receiver or param, "use" for a use of another receiver or param, and
+// empty otherwise.
+type htmlSeg struct {
+	Text  string
+	Class string
+}
+
+type htmlFile struct {
+	Name  string
+	Funcs []*htmlFunc
+}
+
+type htmlPackage struct {
+	Name  string
+	Files []*htmlFile
+}
+
+// writeHTML writes a self-contained HTML page showing the findings,
+// grouped by package and file, with the source of each function. If
+// stats is non-empty, a table of statistics is included.
+func writeHTML(w io.Writer, findings []finding, stats []pkgStats) error {
+	var pkgs []*htmlPackage
+	pkgIndex := make(map[string]*htmlPackage)
+	fileIndex := make(map[string]*htmlFile)
+	funcIndex := make(map[string]*htmlFunc)
+	sources := make(map[string][]byte)
+
+	for _, f := range findings {
+		p := pkgIndex[f.Package]
+		if p == nil {
+			p = &htmlPackage{Name: f.Package}
+			pkgIndex[f.Package] = p
+			pkgs = append(pkgs, p)
+		}
+		name := f.Position.Filename
+		file := fileIndex[name]
+		if file == nil {
+			file = &htmlFile{Name: name}
+			fileIndex[name] = file
+			p.Files = append(p.Files, file)
+		}
+		key := f.FuncPosition.String()
+		fn := funcIndex[key]
+		if fn == nil {
+			fn = &htmlFunc{}
+			funcIndex[key] = fn
+			file.Funcs = append(file.Funcs, fn)
+		}
+		fn.Findings = append(fn.Findings, f)
+
+		if _, ok := sources[name]; !ok {
+			src, err := readFile(name)
+			if err != nil {
+				return err
+			}
+			sources[name] = src
+		}
+	}
+
+	for _, fn := range funcIndex {
+		f := fn.Findings[0]
+		unused := make(map[string]bool)
+		for _, u := range fn.Findings {
+			unused[u.Name] = true
+		}
+		for _, o := range f.Others {
+			if !unused[o.Name] {
+				fn.Others = append(fn.Others, o)
+			}
+		}
+		fn.Lines = sourceLines(sources[f.Position.Filename], fn)
+	}
+
+	return htmlTemplate.Execute(w, struct {
+		Packages []*htmlPackage
+		Stats    []pkgStats
+	}{pkgs, stats})
+}
+
+// sourceLines splits the source of the function into lines, marking
+// the unused receivers and params and the uses of the others.
+func sourceLines(src []byte, fn *htmlFunc) []htmlLine {
+	f := fn.Findings[0]
+	if f.FuncStart < 0 || f.FuncEnd > len(src) || f.FuncStart > f.FuncEnd {
+		return nil // file changed since it was analysed
+	}
+
+	type mark struct {
+		offset, length int
+		class          string
+	}
+	var marks []mark
+	for _, u := range fn.Findings {
+		if strings.HasPrefix(u.Name, "#") {
+			continue // unnamed param of an interface method or func type
+		}
+		marks = append(marks, mark{u.Position.Offset, len(u.Name), "unused"})
+	}
+	for _, o := range fn.Others {
+		for _, u := range o.Uses {
+			marks = append(marks, mark{u.Offset, len(o.Name), "use"})
+		}
+	}
+	sort.Slice(marks, func(i, j int) bool { return marks[i].offset < marks[j].offset })
+
+	// Start at the beginning of the function's first line, so that
+	// indentation is preserved.
+	start := bytes.LastIndexByte(src[:f.FuncStart], '\n') + 1
+	line := htmlLine{Num: f.FuncPosition.Line - bytes.Count(src[start:f.FuncPosition.Offset], []byte("\n"))}
+	var lines []htmlLine
+	add := func(text []byte, class string) {
+		for {
+			i := bytes.IndexByte(text, '\n')
+			if i < 0 {
+				break
+			}
+			if i > 0 {
+				line.Segs = append(line.Segs, htmlSeg{string(text[:i]), class})
+			}
+			lines = append(lines, line)
+			line = htmlLine{Num: line.Num + 1}
+			text = text[i+1:]
+		}
+		if len(text) > 0 {
+			line.Segs = append(line.Segs, htmlSeg{string(text), class})
+		}
+	}
+
+	pos := start
+	for _, m := range marks {
+		if m.offset < pos || m.offset+m.length > f.FuncEnd {
+			continue
+		}
+		add(src[pos:m.offset], "")
+		add(src[m.offset:m.offset+m.length], m.class)
+		pos = m.offset + m.length
+	}
+	add(src[pos:f.FuncEnd], "")
+	return append(lines, line)
+}
+
+// messageHTML returns the message of the finding, with the name of the
+// receiver or param marked as unused.
+func messageHTML(f finding) template.HTML {
+	msg := f.message()
+	word := f.Kind + " " + f.Name
+	i := strings.Index(msg, word)
+	if i < 0 {
+		return template.HTML(template.HTMLEscapeString(msg))
+	}
+	i += len(f.Kind) + 1
+	return template.HTML(template.HTMLEscapeString(msg[:i]) +
+		`<span class="unused">` + template.HTMLEscapeString(f.Name) + `</span>` +
+		template.HTMLEscapeString(msg[i+len(f.Name):]))
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"plural": func(n int, word string) string {
+		if n == 1 {
+			return fmt.Sprintf("%d %s", n, word)
+		}
+		return fmt.Sprintf("%d %ss", n, word)
+	},
+	"message": messageHTML,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>unusedargs report</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #24292e; }
+h1 { font-size: 1.6em; }
+h2 { font-size: 1.3em; border-bottom: 1px solid #e1e4e8; padding-bottom: 0.3em; margin-top: 2em; }
+h3 { font-size: 1em; font-family: Menlo, Consolas, monospace; color: #586069; }
+.func { margin: 1em 0 2em 1em; }
+.func p { margin: 0.3em 0; }
+pre { background: #f6f8fa; padding: 0.8em; overflow-x: auto; font-size: 0.85em; line-height: 1.45; }
+.ln { display: inline-block; width: 3em; color: #959da5; user-select: none; }
+.unused { background: #ffdce0; color: #b31d28; font-weight: bold; }
+.use { background: #dcffe4; }
+table { border-collapse: collapse; font-size: 0.9em; }
+th, td { border: 1px solid #e1e4e8; padding: 0.3em 0.6em; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+</style>
+</head>
+<body>
+<h1>unusedargs report</h1>
+{{if not .Packages}}<p>No unused receivers or params.</p>{{end}}
+{{range .Packages}}
+<h2>{{.Name}}</h2>
+{{range .Files}}
+<h3>{{.Name}}</h3>
+{{range .Funcs}}
+<div class="func">
+{{range .Findings}}<p>{{.FuncPosition}}: {{message .}}{{if .Configs}} {{.Configs}}{{end}}</p>
+{{end}}
+<pre>{{range .Lines}}<span class="ln">{{.Num}}</span>{{range .Segs}}{{if .Class}}<span class="{{.Class}}">{{.Text}}</span>{{else}}{{.Text}}{{end}}{{end}}
+{{end}}</pre>
+{{range .Others}}<p>{{.Kind}} <span class="use">{{.Name}}</span>: {{plural (len .Uses) "use"}}{{range $i, $u := .Uses}}{{if $i}},{{end}} {{$u}}{{end}}</p>
+{{end}}
+</div>
+{{end}}
+{{end}}
+{{end}}
+{{if .Stats}}
+<h2>Statistics</h2>
+<table>
+<tr><th>package</th><th>functions</th><th>receivers</th><th>params</th><th>unused receivers</th><th>unused params</th><th>suppressed</th><th>generated</th></tr>
+{{range .Stats}}<tr><td>{{.Package}}{{if .Failed}} (failed to type check){{end}}</td><td>{{.Functions}}</td><td>{{.Receivers}}</td><td>{{.Params}}</td><td>{{.UnusedReceivers}}</td><td>{{.UnusedParams}}</td><td>{{.Suppressed}}</td><td>{{.Generated}}</td></tr>
+{{end}}
+</table>
+{{end}}
+</body>
+</html>
+`))
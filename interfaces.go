@@ -0,0 +1,88 @@
+package main
+
+import (
+	"go/token"
+	"go/types"
+)
+
+// checkInterfaces is set by -interfaces.
+var checkInterfaces bool
+
+// recordInterfaces records in rep the methods of interfaces declared
+// in the package, and the methods of its concrete types that implement
+// methods of interfaces declared in the package or in the packages it
+// imports. path identifies the package, as for typeKey.
+func recordInterfaces(rep *report, fset *token.FileSet, info *types.Info, label, path string, contents map[string][]byte) {
+	named, pkg := namedTypes(info)
+	if pkg == nil {
+		return
+	}
+	used := usedObjects(info)
+
+	// Find the interfaces that the package's types may implement, and
+	// record the ones declared in the package.
+	keys := make(map[*types.Func]string) // key of each explicit interface method
+	var ifaces []*types.Interface
+	addIface := func(t *types.Named, declared bool) {
+		iface, ok := t.Underlying().(*types.Interface)
+		if !ok || t.TypeParams().Len() > 0 || iface.NumMethods() == 0 {
+			return
+		}
+		ifaces = append(ifaces, iface)
+		for i := 0; i < iface.NumExplicitMethods(); i++ {
+			m := iface.ExplicitMethod(i)
+			key := typeKey(t.Obj(), path) + "." + m.Name()
+			keys[m] = key
+			if !declared {
+				continue
+			}
+			pos := fset.Position(m.Pos())
+			rep.Abstract = append(rep.Abstract, newAbstractFunc(key, label, t.Obj().Name()+"."+m.Name(),
+				t.Obj().Exported() && m.Exported(), m.Type().(*types.Signature), fset, pos, contents[pos.Filename]))
+		}
+	}
+	for _, t := range named {
+		addIface(t, true)
+	}
+	for _, imp := range pkg.Imports() {
+		scope := imp.Scope()
+		for _, name := range scope.Names() {
+			if tn, ok := scope.Lookup(name).(*types.TypeName); ok && !tn.IsAlias() {
+				if t, ok := tn.Type().(*types.Named); ok {
+					addIface(t, false)
+				}
+			}
+		}
+	}
+
+	// Record the methods implementing the interfaces.
+	for _, t := range named {
+		if types.IsInterface(t) {
+			continue
+		}
+		ptr := types.NewPointer(t)
+		for _, iface := range ifaces {
+			if !types.Implements(ptr, iface) {
+				continue
+			}
+			for i := 0; i < iface.NumMethods(); i++ {
+				m := iface.Method(i)
+				key, ok := keys[m]
+				if !ok {
+					continue // declared in an interface we don't know of
+				}
+				obj, _, _ := types.LookupFieldOrMethod(ptr, false, m.Pkg(), m.Name())
+				fn, ok := obj.(*types.Func)
+				if !ok {
+					continue
+				}
+				sig := fn.Type().(*types.Signature)
+				impl := concreteFunc{Key: key, Position: fset.Position(fn.Pos()), Unused: make([]bool, sig.Params().Len())}
+				if fn.Pkg() == pkg {
+					impl.Unused = unusedParams(sig, used)
+				} // else promoted from another package; treat its params as used
+				rep.Impls = append(rep.Impls, impl)
+			}
+		}
+	}
+}
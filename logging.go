@@ -0,0 +1,87 @@
+package main
+
+import (
+	"go/ast"
+	"go/types"
+	"strings"
+)
+
+// checkLogging is set by -logging. If set, receivers and params whose
+// only uses are in arguments to logging functions are reported.
+var checkLogging bool
+
+// extraLogFuncs is set by -logfuncs: a comma-separated list of the
+// full names of more logging functions.
+var extraLogFuncs string
+
+// defaultLogFuncs are the full names, as given by types.Func.FullName,
+// of the standard library's logging functions. fmt's functions aren't
+// among them, since they write output or build values; formatting in
+// an argument to a logging function, as in log.Print(fmt.Sprint(x)),
+// counts as logging.
+var defaultLogFuncs = func() []string {
+	var names []string
+	for _, m := range []string{"Print", "Printf", "Println", "Fatal", "Fatalf", "Fatalln", "Panic", "Panicf", "Panicln"} {
+		names = append(names, "log."+m, "(*log.Logger)."+m)
+	}
+	for _, m := range []string{"Debug", "Info", "Warn", "Error", "DebugContext", "InfoContext", "WarnContext", "ErrorContext", "Log", "LogAttrs"} {
+		names = append(names, "log/slog."+m, "(*log/slog.Logger)."+m)
+	}
+	return names
+}()
+
+// logFuncs returns the set of logging functions: the defaults and
+// those given by -logfuncs.
+func logFuncs() map[string]bool {
+	m := make(map[string]bool)
+	for _, name := range defaultLogFuncs {
+		m[name] = true
+	}
+	for _, name := range strings.Split(extraLogFuncs, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			m[name] = true
+		}
+	}
+	return m
+}
+
+// onlyLogged reports whether all of the uses are within arguments to
+// calls of the logging functions in the function, including within
+// calls made to compute those arguments.
+func onlyLogged(fn ast.Node, uses []*ast.Ident, info *types.Info, funcs map[string]bool) bool {
+	var args []ast.Expr
+	ast.Inspect(fn, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		var id *ast.Ident
+		switch f := ast.Unparen(call.Fun).(type) {
+		case *ast.Ident:
+			id = f
+		case *ast.SelectorExpr:
+			id = f.Sel
+		}
+		if id == nil {
+			return true
+		}
+		if f, ok := info.Uses[id].(*types.Func); ok && funcs[f.FullName()] {
+			args = append(args, call.Args...)
+		}
+		return true
+	})
+
+	for _, u := range uses {
+		logged := false
+		for _, a := range args {
+			if a.Pos() <= u.Pos() && u.End() <= a.End() {
+				logged = true
+				break
+			}
+		}
+		if !logged {
+			return false
+		}
+	}
+	return true
+}
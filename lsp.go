@@ -0,0 +1,443 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/nishanths/unusedargs/usages"
+)
+
+// This file implements the subset of the Language Server Protocol
+// needed to publish diagnostics and offer code actions for unused
+// receivers and params. See
+// https://microsoft.github.io/language-server-protocol/specification.
+
+type rpcRequest struct {
+	ID     *json.RawMessage `json:"id"` // nil for notifications
+	Method string           `json:"method"`
+	Params json.RawMessage  `json:"params"`
+}
+
+type rpcResponse struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Result  interface{}      `json:"result"`
+	Error   *rpcError        `json:"error,omitempty"`
+}
+
+type rpcNotification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+const (
+	errMethodNotFound = -32601
+	errInvalidParams  = -32602
+)
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"` // in UTF-16 code units
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type textDocumentItem struct {
+	URI  string `json:"uri"`
+	Text string `json:"text"`
+}
+
+type textDocumentIdentifier struct {
+	URI string `json:"uri"`
+}
+
+type diagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type textEdit struct {
+	Range   lspRange `json:"range"`
+	NewText string   `json:"newText"`
+}
+
+type codeAction struct {
+	Title       string         `json:"title"`
+	Kind        string         `json:"kind"`
+	Diagnostics []diagnostic   `json:"diagnostics"`
+	Edit        *workspaceEdit `json:"edit"`
+}
+
+type workspaceEdit struct {
+	Changes map[string][]textEdit `json:"changes"`
+}
+
+const severityWarning = 2
+
+// An lspServer holds the state of a Language Server Protocol session.
+type lspServer struct {
+	w io.Writer
+
+	docs     map[string][]byte    // open documents by path
+	uris     map[string]string    // URIs of open documents by path
+	findings map[string][]finding // latest findings by path
+}
+
+// serveLSP serves Language Server Protocol requests read from r,
+// writing responses and notifications to w, until the client sends
+// the exit notification or r is exhausted.
+func serveLSP(r io.Reader, w io.Writer) error {
+	s := &lspServer{
+		w:        w,
+		docs:     make(map[string][]byte),
+		uris:     make(map[string]string),
+		findings: make(map[string][]finding),
+	}
+	tr := textproto.NewReader(bufio.NewReader(r))
+	for {
+		hdr, err := tr.ReadMIMEHeader()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		n, err := strconv.Atoi(hdr.Get("Content-Length"))
+		if err != nil {
+			return fmt.Errorf("lsp: bad Content-Length: %v", err)
+		}
+		body := make([]byte, n)
+		if _, err := io.ReadFull(tr.R, body); err != nil {
+			return err
+		}
+		var req rpcRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return fmt.Errorf("lsp: bad message: %v", err)
+		}
+		if req.Method == "exit" {
+			return nil
+		}
+		if err := s.handle(&req); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *lspServer) handle(req *rpcRequest) error {
+	var result interface{}
+	var rerr *rpcError
+
+	switch req.Method {
+	case "initialize":
+		result = map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":   1, // full document sync
+				"codeActionProvider": true,
+			},
+			"serverInfo": map[string]string{"name": "unusedargs"},
+		}
+	case "shutdown":
+		result = nil
+	case "textDocument/didOpen":
+		var params struct {
+			TextDocument textDocumentItem `json:"textDocument"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			rerr = &rpcError{errInvalidParams, err.Error()}
+			break
+		}
+		return s.update(params.TextDocument.URI, []byte(params.TextDocument.Text))
+	case "textDocument/didChange":
+		var params struct {
+			TextDocument   textDocumentIdentifier `json:"textDocument"`
+			ContentChanges []struct {
+				Text string `json:"text"`
+			} `json:"contentChanges"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil || len(params.ContentChanges) == 0 {
+			rerr = &rpcError{errInvalidParams, "bad didChange params"}
+			break
+		}
+		last := params.ContentChanges[len(params.ContentChanges)-1]
+		return s.update(params.TextDocument.URI, []byte(last.Text))
+	case "textDocument/didClose":
+		var params struct {
+			TextDocument textDocumentIdentifier `json:"textDocument"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			rerr = &rpcError{errInvalidParams, err.Error()}
+			break
+		}
+		return s.close(params.TextDocument.URI)
+	case "textDocument/codeAction":
+		var params struct {
+			TextDocument textDocumentIdentifier `json:"textDocument"`
+			Range        lspRange               `json:"range"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			rerr = &rpcError{errInvalidParams, err.Error()}
+			break
+		}
+		result = s.codeActions(params.TextDocument.URI, params.Range)
+	default:
+		if req.ID == nil {
+			return nil // ignore unknown notifications
+		}
+		rerr = &rpcError{errMethodNotFound, "method not found: " + req.Method}
+	}
+
+	if req.ID == nil {
+		return nil
+	}
+	return s.write(rpcResponse{JSONRPC: "2.0", ID: req.ID, Result: result, Error: rerr})
+}
+
+func (s *lspServer) write(msg interface{}) error {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.w, "Content-Length: %d\r\n\r\n%s", len(b), b)
+	return err
+}
+
+// update records the new contents of the document, re-analyses its
+// package, and publishes diagnostics for the package's open documents.
+func (s *lspServer) update(uri string, text []byte) error {
+	path, err := uriToPath(uri)
+	if err != nil {
+		return nil // not a file; nothing to analyse
+	}
+	s.docs[path] = text
+	s.uris[path] = uri
+
+	dir := filepath.Dir(path)
+	contents, importPath := s.packageContents(dir)
+	rep := analyse(importPath, buildContext.GOARCH, newSharedImporter(defaultImporter(&buildContext)), contents, nil)
+	if rep.Err != nil {
+		// Most likely a syntax error in the document being edited.
+		// Keep the previous diagnostics until it parses again.
+		return nil
+	}
+
+	for p := range s.docs {
+		if filepath.Dir(p) == dir {
+			s.findings[p] = nil
+		}
+	}
+	for _, f := range rep.Findings {
+		if _, ok := s.docs[f.Position.Filename]; ok {
+			s.findings[f.Position.Filename] = append(s.findings[f.Position.Filename], f)
+		}
+	}
+	for p := range s.docs {
+		if filepath.Dir(p) != dir {
+			continue
+		}
+		if err := s.publish(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// packageContents returns the contents of the files in the package in
+// dir, using the open documents in place of the files on disk, and the
+// package's import path if known.
+func (s *lspServer) packageContents(dir string) (contents map[string][]byte, path string) {
+	contents = make(map[string][]byte)
+	if pkg, err := buildContext.ImportDir(dir, 0); err == nil {
+		disk, _, _ := readFiles(pkgFiles(pkg))
+		for p, b := range disk {
+			contents[p] = b
+		}
+		path = importPath(pkg)
+	}
+	for p, b := range s.docs {
+		if filepath.Dir(p) == dir {
+			contents[p] = b
+		}
+	}
+	return contents, path
+}
+
+func (s *lspServer) close(uri string) error {
+	path, err := uriToPath(uri)
+	if err != nil {
+		return nil
+	}
+	if _, ok := s.docs[path]; !ok {
+		return nil
+	}
+	delete(s.docs, path)
+	delete(s.findings, path)
+	err = s.write(rpcNotification{
+		JSONRPC: "2.0",
+		Method:  "textDocument/publishDiagnostics",
+		Params: map[string]interface{}{
+			"uri":         uri,
+			"diagnostics": []diagnostic{},
+		},
+	})
+	delete(s.uris, path)
+	return err
+}
+
+func (s *lspServer) publish(path string) error {
+	diags := []diagnostic{}
+	for _, f := range s.findings[path] {
+		diags = append(diags, s.diagnostic(f))
+	}
+	return s.write(rpcNotification{
+		JSONRPC: "2.0",
+		Method:  "textDocument/publishDiagnostics",
+		Params: map[string]interface{}{
+			"uri":         s.uris[path],
+			"diagnostics": diags,
+		},
+	})
+}
+
+func (s *lspServer) diagnostic(f finding) diagnostic {
+	src := s.docs[f.Position.Filename]
+	return diagnostic{
+		Range:    offsetRange(src, f.Position.Offset, f.Position.Offset+len(f.Name)),
+		Severity: severityWarning,
+		Source:   "unusedargs",
+		Message:  f.message(),
+	}
+}
+
+// codeActions returns the code actions for the findings in the
+// document that overlap rng.
+func (s *lspServer) codeActions(uri string, rng lspRange) []codeAction {
+	actions := []codeAction{}
+	path, err := uriToPath(uri)
+	if err != nil {
+		return actions
+	}
+	src := s.docs[path]
+	for _, f := range s.findings[path] {
+		d := s.diagnostic(f)
+		if less(d.Range.End, rng.Start) || less(rng.End, d.Range.Start) {
+			continue
+		}
+		edit := func(e textEdit) *workspaceEdit {
+			return &workspaceEdit{Changes: map[string][]textEdit{uri: {e}}}
+		}
+
+		switch {
+		case f.Chan != "":
+			if f.ChanFix > 0 {
+				actions = append(actions, codeAction{
+					Title:       fmt.Sprintf("Change type of param %s to %s", f.Name, f.Chan),
+					Kind:        "quickfix",
+					Diagnostics: []diagnostic{d},
+					Edit:        edit(textEdit{Range: offsetRange(src, f.ChanFix, f.ChanFix), NewText: "<-"}),
+				})
+			}
+		case !f.isUnused():
+			// Used, so neither renaming nor dropping the name compiles.
+		case f.Kind == usages.FuncParam || f.Kind == kindContext:
+			actions = append(actions, codeAction{
+				Title:       fmt.Sprintf("Rename unused param %s to _", f.Name),
+				Kind:        "quickfix",
+				Diagnostics: []diagnostic{d},
+				Edit:        edit(textEdit{Range: d.Range, NewText: "_"}),
+			})
+		case f.Kind == usages.FuncReceiver:
+			// Remove the name and the space separating it from the type.
+			end := f.Position.Offset + len(f.Name)
+			for end < len(src) && (src[end] == ' ' || src[end] == '\t') {
+				end++
+			}
+			actions = append(actions, codeAction{
+				Title:       fmt.Sprintf("Drop name of unused receiver %s", f.Name),
+				Kind:        "quickfix",
+				Diagnostics: []diagnostic{d},
+				Edit:        edit(textEdit{Range: offsetRange(src, f.Position.Offset, end), NewText: ""}),
+			})
+		}
+
+		// Insert the directive above the function's line, with the
+		// same indentation.
+		lineStart := f.FuncPosition.Offset - (f.FuncPosition.Column - 1)
+		i := lineStart
+		for i < len(src) && (src[i] == ' ' || src[i] == '\t') {
+			i++
+		}
+		indent := src[lineStart:i]
+		actions = append(actions, codeAction{
+			Title:       fmt.Sprintf("Suppress report for %s %s", f.Kind, f.Name),
+			Kind:        "quickfix",
+			Diagnostics: []diagnostic{d},
+			Edit: edit(textEdit{
+				Range:   offsetRange(src, lineStart, lineStart),
+				NewText: fmt.Sprintf("%s%s %s\n", indent, directive, f.Name),
+			}),
+		})
+	}
+	return actions
+}
+
+func less(a, b lspPosition) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Character < b.Character
+}
+
+// offsetRange converts the byte offsets in src to an LSP range.
+func offsetRange(src []byte, start, end int) lspRange {
+	return lspRange{Start: offsetPosition(src, start), End: offsetPosition(src, end)}
+}
+
+// offsetPosition converts the byte offset in src to an LSP position,
+// which counts characters in UTF-16 code units.
+func offsetPosition(src []byte, offset int) lspPosition {
+	if offset > len(src) {
+		offset = len(src)
+	}
+	line := bytes.Count(src[:offset], []byte("\n"))
+	lineStart := bytes.LastIndexByte(src[:offset], '\n') + 1
+	char := 0
+	for b := src[lineStart:offset]; len(b) > 0; {
+		r, size := utf8.DecodeRune(b)
+		char += len(utf16.Encode([]rune{r}))
+		b = b[size:]
+	}
+	return lspPosition{Line: line, Character: char}
+}
+
+func uriToPath(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "file" {
+		return "", fmt.Errorf("unsupported URI scheme %q", u.Scheme)
+	}
+	p := filepath.FromSlash(u.Path)
+	// file:///C:/foo on Windows.
+	if len(p) >= 3 && p[0] == filepath.Separator && p[2] == ':' {
+		p = p[1:]
+	}
+	return filepath.Clean(p), nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestLSP(t *testing.T) {
+	const uri = "file:///nonexistent/authurl/main.go"
+	const src = `package main
+
+import "fmt"
+
+func authURL(clientID, code int, state string) string {
+	return fmt.Sprintf("https://example.org/?client_id=%d&code=%d", clientID, code)
+}
+`
+	msgs := lspSession(t, uri, src, lspRange{Start: lspPosition{4, 35}, End: lspPosition{4, 35}})
+	if len(msgs) != 3 {
+		t.Fatalf("want 3 messages, got %d", len(msgs))
+	}
+
+	var diags struct {
+		Params struct {
+			URI         string       `json:"uri"`
+			Diagnostics []diagnostic `json:"diagnostics"`
+		} `json:"params"`
+	}
+	if err := json.Unmarshal(msgs[1], &diags); err != nil {
+		t.Fatal(err)
+	}
+	wantRange := lspRange{Start: lspPosition{4, 33}, End: lspPosition{4, 38}}
+	if len(diags.Params.Diagnostics) != 1 {
+		t.Fatalf("want 1 diagnostic, got %+v", diags.Params.Diagnostics)
+	}
+	if d := diags.Params.Diagnostics[0]; d.Message != "authURL has unused param state" || d.Range != wantRange {
+		t.Errorf("unexpected diagnostic %+v", d)
+	}
+
+	var actions struct {
+		Result []codeAction `json:"result"`
+	}
+	if err := json.Unmarshal(msgs[2], &actions); err != nil {
+		t.Fatal(err)
+	}
+	want := []textEdit{
+		{Range: wantRange, NewText: "_"},
+		{Range: lspRange{Start: lspPosition{4, 0}, End: lspPosition{4, 0}}, NewText: "//unusedargs:ignore state\n"},
+	}
+	if len(actions.Result) != len(want) {
+		t.Fatalf("want %d code actions, got %+v", len(want), actions.Result)
+	}
+	for i, a := range actions.Result {
+		edits := a.Edit.Changes[uri]
+		if len(edits) != 1 || edits[0] != want[i] {
+			t.Errorf("code action %q: want edit %+v, got %+v", a.Title, want[i], edits)
+		}
+	}
+}
+
+// A param that is used, but reported, such as for only being logged,
+// can't be renamed to _.
+func TestLSPUsedParam(t *testing.T) {
+	defer func(b bool) { checkLogging = b }(checkLogging)
+	checkLogging = true
+
+	const uri = "file:///nonexistent/save/main.go"
+	const src = `package main
+
+import "log"
+
+func save(id string) {
+	log.Printf("saving %s", id)
+}
+`
+	msgs := lspSession(t, uri, src, lspRange{Start: lspPosition{4, 10}, End: lspPosition{4, 10}})
+	if len(msgs) != 3 {
+		t.Fatalf("want 3 messages, got %d", len(msgs))
+	}
+	var actions struct {
+		Result []codeAction `json:"result"`
+	}
+	if err := json.Unmarshal(msgs[2], &actions); err != nil {
+		t.Fatal(err)
+	}
+	if len(actions.Result) != 1 || !strings.HasPrefix(actions.Result[0].Title, "Suppress report") {
+		t.Errorf("want only the suppress code action, got %+v", actions.Result)
+	}
+}
+
+// lspSession runs the server on a session opening the document and
+// asking for the code actions in rng, and returns the messages it
+// wrote.
+func lspSession(t *testing.T, uri, src string, rng lspRange) []json.RawMessage {
+	var in bytes.Buffer
+	send := func(id int, method string, params interface{}) {
+		msg := map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params}
+		if id != 0 {
+			msg["id"] = id
+		}
+		b, err := json.Marshal(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(b), b)
+	}
+	send(1, "initialize", map[string]interface{}{})
+	send(0, "textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri, "languageId": "go", "version": 1, "text": src},
+	})
+	send(2, "textDocument/codeAction", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri},
+		"range":        rng,
+	})
+	send(0, "exit", nil)
+
+	var out bytes.Buffer
+	if err := serveLSP(&in, &out); err != nil {
+		t.Fatal(err)
+	}
+	return readMessages(t, &out)
+}
+
+func readMessages(t *testing.T, r io.Reader) []json.RawMessage {
+	var msgs []json.RawMessage
+	tr := textproto.NewReader(bufio.NewReader(r))
+	for {
+		hdr, err := tr.ReadMIMEHeader()
+		if err == io.EOF {
+			return msgs
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := strconv.Atoi(hdr.Get("Content-Length"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := make([]byte, n)
+		if _, err := io.ReadFull(tr.R, b); err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, b)
+	}
+}
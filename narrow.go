@@ -0,0 +1,119 @@
+package main
+
+import (
+	"go/ast"
+	"go/types"
+	"sort"
+)
+
+// checkNarrow is set by -narrow. If set, params of concrete types that
+// are only used to call methods of an interface are reported, with the
+// interface.
+var checkNarrow bool
+
+// stdInterfaces are the standard library packages whose interfaces are
+// suggested, in addition to those of the checked package and its
+// imports.
+var stdInterfaces = []string{"io", "fmt", "sort"}
+
+// interfaceCandidates returns the non-empty interfaces that can be
+// named in pkg: those declared in it, and the exported ones declared
+// in its imports and in the stdInterfaces packages.
+func interfaceCandidates(pkg *types.Package, imp types.Importer) []*types.TypeName {
+	var cands []*types.TypeName
+	seen := make(map[*types.Package]bool)
+	add := func(p *types.Package) {
+		if p == nil || seen[p] {
+			return
+		}
+		seen[p] = true
+		scope := p.Scope()
+		for _, name := range scope.Names() {
+			tn, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || (p != pkg && !tn.Exported()) {
+				continue
+			}
+			if n, ok := tn.Type().(*types.Named); ok && n.TypeParams().Len() == 0 {
+				if iface, ok := n.Underlying().(*types.Interface); ok && iface.NumMethods() > 0 {
+					cands = append(cands, tn)
+				}
+			}
+		}
+	}
+	add(pkg)
+	for _, p := range pkg.Imports() {
+		add(p)
+	}
+	for _, path := range stdInterfaces {
+		if p, err := imp.Import(path); err == nil {
+			add(p)
+		}
+	}
+	return cands
+}
+
+// narrowerInterface returns the name, as written in the param's
+// package, of the interface among cands with the fewest methods that
+// the param's type implements and that has all of the methods called
+// on the param, and the names of those methods. It returns the empty
+// string if the param is of an interface type, or has a use other
+// than a method call.
+func narrowerInterface(fn ast.Node, obj types.Object, uses []*ast.Ident, info *types.Info, cands []*types.TypeName) (string, []string) {
+	if obj == nil || len(uses) == 0 || types.IsInterface(obj.Type()) {
+		return "", nil
+	}
+
+	// Find the methods called on the uses.
+	called := make(map[*ast.Ident]string)
+	ast.Inspect(fn, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		sel, ok := ast.Unparen(call.Fun).(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if x, ok := ast.Unparen(sel.X).(*ast.Ident); ok {
+			if s := info.Selections[sel]; s != nil && s.Kind() == types.MethodVal {
+				called[x] = sel.Sel.Name
+			}
+		}
+		return true
+	})
+	methodSet := make(map[string]bool)
+	for _, u := range uses {
+		m, ok := called[u]
+		if !ok {
+			return "", nil // used other than to call a method
+		}
+		methodSet[m] = true
+	}
+	var methods []string
+	for m := range methodSet {
+		methods = append(methods, m)
+	}
+	sort.Strings(methods)
+
+	var best *types.TypeName
+	bestLen := 0
+	for _, tn := range cands {
+		iface := tn.Type().Underlying().(*types.Interface)
+		if best != nil && iface.NumMethods() >= bestLen {
+			continue
+		}
+		has := 0
+		for i := 0; i < iface.NumMethods(); i++ {
+			if methodSet[iface.Method(i).Name()] {
+				has++
+			}
+		}
+		if has == len(methods) && types.Implements(obj.Type(), iface) {
+			best, bestLen = tn, iface.NumMethods()
+		}
+	}
+	if best == nil {
+		return "", nil
+	}
+	return types.TypeString(best.Type(), types.RelativeTo(obj.Pkg())), methods
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// overlay replaces the contents of files on disk. It maps absolute
+// paths to contents, and is set up before any packages are checked.
+// Files in the overlay need not exist on disk.
+var overlay = make(map[string][]byte)
+
+// deleted holds the absolute paths of files that are treated as if
+// they don't exist, as specified by -overlay.
+var deleted = make(map[string]bool)
+
+// reportOnly, if non-nil, restricts reports to these files, by
+// absolute path. It is set by -staged and -stdin.
+var reportOnly map[string]bool
+
+func init() {
+	buildContext.OpenFile = openFile
+	buildContext.ReadDir = readDir
+}
+
+// readFile is like ioutil.ReadFile, but reads from the overlay if the
+// file is in it.
+func readFile(name string) ([]byte, error) {
+	abs := absPath(name)
+	if b, ok := overlay[abs]; ok {
+		return b, nil
+	}
+	if deleted[abs] {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	return ioutil.ReadFile(name)
+}
+
+// openFile is a build.Context.OpenFile that reads from the overlay,
+// so that build constraints are evaluated on the overlay contents.
+func openFile(name string) (io.ReadCloser, error) {
+	b, err := readFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(b)), nil
+}
+
+// readDir is a build.Context.ReadDir that includes the files in the
+// overlay, and excludes deleted files.
+func readDir(dir string) ([]os.FileInfo, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	absDir := absPath(dir)
+	byName := make(map[string]os.FileInfo)
+	for _, fi := range infos {
+		if !deleted[filepath.Join(absDir, fi.Name())] {
+			byName[fi.Name()] = fi
+		}
+	}
+	for abs, b := range overlay {
+		if filepath.Dir(abs) == absDir {
+			name := filepath.Base(abs)
+			byName[name] = overlayFileInfo{name: name, size: int64(len(b))}
+		}
+	}
+	if err != nil && len(byName) == 0 {
+		return nil, err // no such directory, on disk or in the overlay
+	}
+
+	infos = infos[:0]
+	for _, fi := range byName {
+		infos = append(infos, fi)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+	return infos, nil
+}
+
+// overlayFileInfo describes a file in the overlay.
+type overlayFileInfo struct {
+	name string
+	size int64
+}
+
+func (fi overlayFileInfo) Name() string       { return fi.name }
+func (fi overlayFileInfo) Size() int64        { return fi.size }
+func (fi overlayFileInfo) Mode() os.FileMode  { return 0444 }
+func (fi overlayFileInfo) ModTime() time.Time { return time.Time{} }
+func (fi overlayFileInfo) IsDir() bool        { return false }
+func (fi overlayFileInfo) Sys() interface{}   { return nil }
+
+// loadOverlay adds the replacements in the overlay file to the overlay.
+// The file has the format used by go build -overlay: a JSON object
+// with a Replace field mapping file paths to the paths of files with
+// their new contents, or to the empty string if they are deleted.
+// Relative paths are relative to the current directory.
+func loadOverlay(file string) error {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	var o struct {
+		Replace map[string]string
+	}
+	if err := json.Unmarshal(b, &o); err != nil {
+		return fmt.Errorf("parsing overlay %s: %v", file, err)
+	}
+	for from, to := range o.Replace {
+		abs := absPath(from)
+		if to == "" {
+			deleted[abs] = true
+			continue
+		}
+		b, err := ioutil.ReadFile(to)
+		if err != nil {
+			return fmt.Errorf("reading overlay %s: %v", file, err)
+		}
+		overlay[abs] = b
+	}
+	return nil
+}
+
+// addStdin adds the contents of r to the overlay as the file with the
+// name, restricts reports to the file, and returns the file's directory.
+func addStdin(r io.Reader, name string) (dir string, err error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	abs := absPath(name)
+	overlay[abs] = b
+	reportOnly = map[string]bool{abs: true}
+	return filepath.Dir(name), nil
+}
+
+func absPath(name string) string {
+	if abs, err := filepath.Abs(name); err == nil {
+		return abs
+	}
+	return name
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOverlay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "unusedargs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func() {
+		overlay = make(map[string][]byte)
+		deleted = make(map[string]bool)
+		reportOnly = nil
+	}()
+
+	write := func(name, src string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.go", "package a\n\nfunc A(x int) {}\n")
+	write("b.go", "package a\n\nfunc B(y int) {}\n")
+	write("b_new.go", "package a\n\nfunc B(y int) { _ = y }\n")
+	write("overlay.json", `{"Replace": {"`+filepath.Join(dir, "b.go")+`": "`+filepath.Join(dir, "b_new.go")+`", "`+filepath.Join(dir, "b_new.go")+`": ""}}`)
+
+	check := func() []string {
+		rep := checkDir(&buildContext, dir)
+		if rep.Err != nil {
+			t.Fatal(rep.Err)
+		}
+		var got []string
+		for _, f := range rep.Findings {
+			if matchesFilters(f) {
+				got = append(got, strings.TrimPrefix(f.String(), dir+string(filepath.Separator)))
+			}
+		}
+		return got
+	}
+
+	if err := loadOverlay(filepath.Join(dir, "overlay.json")); err != nil {
+		t.Fatal(err)
+	}
+	if want, got := []string{"a.go:3:6: A has unused param x"}, check(); !reflect.DeepEqual(want, got) {
+		t.Errorf("overlay: want %v, got %v", want, got)
+	}
+
+	// The stdin file doesn't exist on disk.
+	d, err := addStdin(strings.NewReader("package a\n\nfunc C(z int) {}\n"), filepath.Join(dir, "c.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d != dir {
+		t.Errorf("want dir %s, got %s", dir, d)
+	}
+	if want, got := []string{"c.go:3:6: C has unused param z"}, check(); !reflect.DeepEqual(want, got) {
+		t.Errorf("stdin: want %v, got %v", want, got)
+	}
+}
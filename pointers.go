@@ -0,0 +1,279 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+// checkPointers is set by -pointers. If set, pointer receivers and
+// params to small values that are only read through are reported, as
+// are value receivers whose fields are assigned to.
+var checkPointers bool
+
+// maxValueSize is the size in bytes of the largest value that a pointer
+// receiver or param to it is reported as could be passed by value.
+const maxValueSize = 64
+
+// An access is a use of a receiver or param, extended through the field
+// selections, array indexing, and dereferences that access the memory
+// of the variable or, for a pointer, of what it points to.
+type access struct {
+	expr   ast.Expr    // the outermost such expression
+	parent ast.Node    // the node containing expr
+	depth  int         // number of selections, indexings, and dereferences
+	method *types.Func // the method called on expr, if any
+}
+
+// accesses returns the accesses of the uses of the receiver or param
+// in the function, and whether any is in a function literal.
+func accesses(fn ast.Node, uses []*ast.Ident, info *types.Info) ([]access, bool) {
+	isUse := make(map[*ast.Ident]bool)
+	for _, u := range uses {
+		isUse[u] = true
+	}
+	var accs []access
+	inLit := false
+	var stack []ast.Node
+	ast.Inspect(fn, func(n ast.Node) bool {
+		if n == nil {
+			stack = stack[:len(stack)-1]
+			return true
+		}
+		stack = append(stack, n)
+		u, ok := n.(*ast.Ident)
+		if !ok || !isUse[u] {
+			return true
+		}
+		for _, p := range stack[1 : len(stack)-1] {
+			if _, ok := p.(*ast.FuncLit); ok {
+				inLit = true
+			}
+		}
+
+		a := access{expr: u}
+		i := len(stack) - 2
+	walk:
+		for ; i >= 0; i-- {
+			t := info.TypeOf(a.expr)
+			if t == nil {
+				break
+			}
+			_, ptr := t.Underlying().(*types.Pointer)
+			if ptr && a.depth > 0 {
+				break // through another pointer
+			}
+			switch p := stack[i].(type) {
+			case *ast.ParenExpr:
+				a.expr = p
+				continue
+			case *ast.StarExpr:
+				if !ptr {
+					break walk
+				}
+				a.expr = p
+			case *ast.SelectorExpr:
+				s := info.Selections[p]
+				if s == nil || p.X != a.expr {
+					break walk
+				}
+				if s.Kind() == types.MethodVal {
+					a.method, _ = s.Obj().(*types.Func)
+					break walk
+				}
+				if s.Kind() != types.FieldVal || s.Indirect() && (a.depth > 0 || len(s.Index()) > 1) {
+					break walk // through an embedded pointer
+				}
+				a.expr = p
+			case *ast.IndexExpr:
+				t = t.Underlying()
+				if pt, ok := t.(*types.Pointer); ok {
+					t = pt.Elem().Underlying()
+				}
+				if _, ok := t.(*types.Array); !ok || p.X != a.expr {
+					break walk
+				}
+				a.expr = p
+			default:
+				break walk
+			}
+			a.depth++
+		}
+		if i >= 0 {
+			a.parent = stack[i]
+		}
+		accs = append(accs, a)
+		return true
+	})
+	return accs, inLit
+}
+
+// isWrite reports whether the access stores to, or takes the address
+// of, the memory it accesses.
+func (a access) isWrite() bool {
+	if a.method != nil {
+		_, ptr := a.method.Type().(*types.Signature).Recv().Type().(*types.Pointer)
+		return ptr
+	}
+	switch p := a.parent.(type) {
+	case *ast.AssignStmt:
+		for _, lhs := range p.Lhs {
+			if lhs == a.expr {
+				return true
+			}
+		}
+	case *ast.IncDecStmt:
+		return p.X == a.expr
+	case *ast.UnaryExpr:
+		return p.Op == token.AND
+	case *ast.RangeStmt:
+		return p.Tok == token.ASSIGN && (p.Key == a.expr || p.Value == a.expr)
+	}
+	return false
+}
+
+// passableByValue reports whether the pointer receiver or param is
+// only used to read the small value it points to, so that it could be
+// passed by value: it is never written through, never has its address
+// or that of what it points to taken, and is never used as a pointer,
+// such as by being passed on, compared, or captured by a function
+// literal. A receiver isn't, if another method of its type has a
+// pointer receiver, so that the type's receivers stay consistent.
+func passableByValue(fn ast.Node, obj types.Object, uses []*ast.Ident, info *types.Info, sizes types.Sizes) bool {
+	if obj == nil || len(uses) == 0 {
+		return false
+	}
+	ptr, ok := obj.Type().(*types.Pointer)
+	if !ok {
+		return false
+	}
+	elem := ptr.Elem()
+	switch elem.Underlying().(type) {
+	case *types.Interface, *types.TypeParam:
+		return false
+	}
+	if hasTypeParams(elem, make(map[types.Type]bool)) {
+		return false // its size depends on the instantiation
+	}
+	if sizes.Sizeof(elem) > maxValueSize || hasLock(elem, make(map[types.Type]bool)) {
+		return false
+	}
+	if decl, ok := fn.(*ast.FuncDecl); ok && decl.Recv != nil && otherPointerMethods(decl, elem, info) {
+		return false
+	}
+
+	accs, inLit := accesses(fn, uses, info)
+	if inLit {
+		return false
+	}
+	for _, a := range accs {
+		if a.isWrite() || a.depth == 0 && a.method == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// lostWrite returns the position of the first assignment to a field of
+// the value receiver, which the caller doesn't see, or token.NoPos if
+// there is none. Receivers that are used as a whole, such as by being
+// returned or passed on, aren't considered, since those may be meant
+// to return a modified copy.
+func lostWrite(fn ast.Node, obj types.Object, uses []*ast.Ident, info *types.Info) token.Pos {
+	if obj == nil || len(uses) == 0 {
+		return token.NoPos
+	}
+	switch obj.Type().Underlying().(type) {
+	case *types.Struct, *types.Array:
+	default:
+		return token.NoPos
+	}
+
+	accs, _ := accesses(fn, uses, info)
+	pos := token.NoPos
+	for _, a := range accs {
+		switch {
+		case a.depth == 0:
+			return token.NoPos // used as a whole
+		case a.method != nil && a.isWrite():
+			return token.NoPos // address taken to call a method
+		case a.isWrite() && (!pos.IsValid() || a.expr.Pos() < pos):
+			pos = a.expr.Pos()
+		}
+	}
+	return pos
+}
+
+// otherPointerMethods reports whether a method of the named type t other
+// than the one declared by decl has a pointer receiver.
+func otherPointerMethods(decl *ast.FuncDecl, t types.Type, info *types.Info) bool {
+	named, ok := t.(*types.Named)
+	if !ok {
+		return false
+	}
+	named = named.Origin()
+	self := info.Defs[decl.Name]
+	for i := 0; i < named.NumMethods(); i++ {
+		m := named.Method(i)
+		if m == self {
+			continue
+		}
+		if _, ptr := m.Type().(*types.Signature).Recv().Type().(*types.Pointer); ptr {
+			return true
+		}
+	}
+	return false
+}
+
+// hasLock reports whether values of type t contain a value that must
+// not be copied: one from sync or sync/atomic, or one with a Lock
+// method.
+func hasLock(t types.Type, seen map[types.Type]bool) bool {
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+	if n, ok := t.(*types.Named); ok {
+		if pkg := n.Obj().Pkg(); pkg != nil && (pkg.Path() == "sync" || pkg.Path() == "sync/atomic") {
+			return true
+		}
+		if types.NewMethodSet(types.NewPointer(t)).Lookup(nil, "Lock") != nil {
+			return true
+		}
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Struct:
+		for i := 0; i < u.NumFields(); i++ {
+			if hasLock(u.Field(i).Type(), seen) {
+				return true
+			}
+		}
+	case *types.Array:
+		return hasLock(u.Elem(), seen)
+	}
+	return false
+}
+
+// hasTypeParams reports whether the size of values of type t depends on
+// type parameters, as that of a generic struct such as Pair[T] does.
+func hasTypeParams(t types.Type, seen map[types.Type]bool) bool {
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+	switch u := types.Unalias(t).(type) {
+	case *types.TypeParam:
+		return true
+	case *types.Named:
+		return hasTypeParams(u.Underlying(), seen)
+	case *types.Struct:
+		for i := 0; i < u.NumFields(); i++ {
+			if hasTypeParams(u.Field(i).Type(), seen) {
+				return true
+			}
+		}
+	case *types.Array:
+		return hasTypeParams(u.Elem(), seen)
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"go/build"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"sync"
+
+	"github.com/nishanths/unusedargs/usages"
+)
+
+// A task checks a single target and returns its report.
+type task func() *report
+
+// runTasks runs tasks using at most n goroutines. fn is called
+// with each report in the order of tasks, as soon as the report and all
+// the reports before it are available. fn is never called concurrently.
+func runTasks(tasks []task, n int, fn func(*report)) {
+	done := make([]chan *report, len(tasks))
+	for i := range done {
+		done[i] = make(chan *report, 1)
+	}
+
+	var wg sync.WaitGroup
+	next := make(chan int)
+	for w := 0; w < n && w < len(tasks); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				done[i] <- tasks[i]()
+			}
+		}()
+	}
+	go func() {
+		for i := range tasks {
+			next <- i
+		}
+		close(next)
+	}()
+
+	for _, c := range done {
+		fn(<-c)
+	}
+	wg.Wait()
+}
+
+// A sharedImporter imports the dependencies of the packages checked
+// together, so that each dependency is looked up once. Imported
+// positions are recorded in fset, which the packages must be parsed
+// into. It is safe for concurrent use.
+type sharedImporter struct {
+	fset *token.FileSet
+
+	mu  sync.Mutex
+	imp types.Importer
+}
+
+// newSharedImporter returns a sharedImporter using the importer returned
+// by newImporter for a new file set.
+func newSharedImporter(newImporter func(*token.FileSet) types.Importer) *sharedImporter {
+	fset := token.NewFileSet()
+	return &sharedImporter{fset: fset, imp: newImporter(fset)}
+}
+
+// defaultImporter returns a function that returns the importer named
+// by -importer for the build configuration ctx. Dependencies are read
+// from disk rather than the overlay, since go/build only resolves
+// imports in module mode for contexts without file system hooks.
+func defaultImporter(ctx *build.Context) func(*token.FileSet) types.Importer {
+	c := diskContext(ctx)
+	return func(fset *token.FileSet) types.Importer {
+		return usages.NewImporter(importerName, c, fset)
+	}
+}
+
+// diskContext returns a copy of ctx that reads files from disk rather
+// than from the overlay.
+func diskContext(ctx *build.Context) *build.Context {
+	c := *ctx
+	c.OpenFile, c.ReadDir = nil, nil
+	return &c
+}
+
+// importFromDisk is like ctx.Import, but reads files from disk, and
+// resolves imports in module mode from the module containing srcDir
+// rather than the one containing the current directory.
+func importFromDisk(ctx *build.Context, path, srcDir string) (*build.Package, error) {
+	c := diskContext(ctx)
+	if abs, err := filepath.Abs(srcDir); err == nil {
+		c.Dir, srcDir = abs, abs
+	}
+	return c.Import(path, srcDir, 0)
+}
+
+func (s *sharedImporter) Import(path string) (*types.Package, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.imp.Import(path)
+}
+
+func (s *sharedImporter) ImportFrom(path, dir string, mode types.ImportMode) (*types.Package, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if from, ok := s.imp.(types.ImporterFrom); ok {
+		return from.ImportFrom(path, dir, mode)
+	}
+	return s.imp.Import(path)
+}
+
+// runImporters holds the importer of each build configuration for the
+// current run.
+var runImporters struct {
+	sync.Mutex
+	m map[*build.Context]*sharedImporter
+}
+
+// importerFor returns the importer shared by the packages checked in
+// the build configuration ctx in the current run.
+func importerFor(ctx *build.Context) *sharedImporter {
+	runImporters.Lock()
+	defer runImporters.Unlock()
+	if runImporters.m == nil {
+		runImporters.m = make(map[*build.Context]*sharedImporter)
+	}
+	imp, ok := runImporters.m[ctx]
+	if !ok {
+		imp = newSharedImporter(defaultImporter(ctx))
+		runImporters.m[ctx] = imp
+	}
+	return imp
+}
+
+// resetImporters discards the importers of the current run, so that
+// the next run imports dependencies that may have changed afresh.
+func resetImporters() {
+	runImporters.Lock()
+	runImporters.m = nil
+	runImporters.Unlock()
+}
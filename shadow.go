@@ -0,0 +1,31 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+// shadowedAt returns the position of the declaration shadowing the
+// unused receiver or param id at the first place its name appears to
+// be used in the function, or token.NoPos if its name never appears.
+// Such an input was probably meant to be used.
+func shadowedAt(fn ast.Node, id *ast.Ident, info *types.Info) token.Pos {
+	first := token.NoPos
+	var decl token.Pos
+	ast.Inspect(fn, func(n ast.Node) bool {
+		use, ok := n.(*ast.Ident)
+		if !ok || use.Name != id.Name {
+			return true
+		}
+		obj := info.Uses[use]
+		if obj == nil || obj.Pos() == id.Pos() || obj.Pos() < fn.Pos() || obj.Pos() >= fn.End() {
+			return true // not shadowed within the function
+		}
+		if !first.IsValid() || use.Pos() < first {
+			first, decl = use.Pos(), obj.Pos()
+		}
+		return true
+	})
+	return decl
+}
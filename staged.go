@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// addStaged adds the staged contents of the changed .go files in the
+// git index to the overlay, and returns the files' directories relative
+// to the current directory, sorted.
+func addStaged() (dirs []string, err error) {
+	top, err := git("rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	root := strings.TrimSpace(string(top))
+
+	out, err := git("diff", "--cached", "--name-only", "--diff-filter=ACMR", "-z")
+	if err != nil {
+		return nil, err
+	}
+	reportOnly = make(map[string]bool)
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(string(out), "\x00") {
+		if !strings.HasSuffix(name, ".go") {
+			continue
+		}
+		b, err := git("show", ":"+name)
+		if err != nil {
+			return nil, err
+		}
+		abs := filepath.Join(root, filepath.FromSlash(name))
+		overlay[abs] = b
+		reportOnly[abs] = true
+
+		dir := filepath.Dir(abs)
+		if rel, err := filepath.Rel(absPath("."), dir); err == nil {
+			dir = rel
+		}
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Strings(dirs)
+	return dirs, nil
+}
+
+// git runs git with the arguments and returns its standard output.
+func git(args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %v: %s", strings.Join(args, " "), err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return out, nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestStaged(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	dir, err := ioutil.TempDir("", "unusedargs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name, src string) {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+
+	run("init", "-q")
+	write("a/a.go", "package a\n\nfunc A(x int) {}\n")
+	write("a/b.go", "package a\n\nfunc B(y int) {}\n")
+	write("c/c.go", "package c\n\nfunc C(z int) {}\n")
+	run("add", ".")
+	run("-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "initial")
+
+	// Stage a change that leaves the param unused, then use it in the
+	// working tree only.
+	write("a/a.go", "package a\n\nfunc A(x, w int) { _ = x }\n")
+	run("add", "a/a.go")
+	write("a/a.go", "package a\n\nfunc A(x, w int) { _, _ = x, w }\n")
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	defer func() {
+		overlay = make(map[string][]byte)
+		deleted = make(map[string]bool)
+		reportOnly = nil
+	}()
+
+	dirs, err := addStaged()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a"}; !reflect.DeepEqual(want, dirs) {
+		t.Fatalf("want dirs %v, got %v", want, dirs)
+	}
+
+	rep := checkDir(&buildContext, "a")
+	if rep.Err != nil {
+		t.Fatal(rep.Err)
+	}
+	var got []string
+	for _, f := range rep.Findings {
+		if matchesFilters(f) {
+			got = append(got, f.String())
+		}
+	}
+	if want := []string{"a/a.go:3:6: A has unused param w"}; !reflect.DeepEqual(want, got) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"text/tabwriter"
+
+	"github.com/nishanths/unusedargs/usages"
+)
+
+// pkgStats are the statistics for a package.
+type pkgStats struct {
+	Package         string `json:"package"`
+	Functions       int    `json:"functions"` // functions with named receivers or params
+	Receivers       int    `json:"receivers"` // named receivers analysed
+	Params          int    `json:"params"`    // named params analysed
+	UnusedReceivers int    `json:"unusedReceivers"`
+	UnusedParams    int    `json:"unusedParams"`
+	Suppressed      int    `json:"suppressed"` // unused, but suppressed by directive or //export
+	Generated       int    `json:"generated"`  // unused, but skipped in generated files
+	Failed          bool   `json:"failed"`     // failed to type check
+}
+
+func (s *pkgStats) add(t pkgStats) {
+	s.Functions += t.Functions
+	s.Receivers += t.Receivers
+	s.Params += t.Params
+	s.UnusedReceivers += t.UnusedReceivers
+	s.UnusedParams += t.UnusedParams
+	s.Suppressed += t.Suppressed
+	s.Generated += t.Generated
+}
+
+// An analysedInput is a receiver or param that was analysed, as
+// counted in statistics.
+type analysedInput struct {
+	Package      string // as labelled in statistics
+	FuncPosition token.Position
+	Position     token.Position
+	Kind         string // usages.FuncReceiver or usages.FuncParam
+	Suppressed   bool   // unused, but suppressed by directive or //export
+	Generated    bool   // unused, but skipped in a generated file
+}
+
+// countStats returns the statistics for the packages of the report,
+// counting its inputs, and the findings of unused inputs among those
+// reported, which have been filtered.
+func countStats(rep *report, reported []finding) []pkgStats {
+	stats := append([]pkgStats(nil), rep.Stats...)
+	index := make(map[string]*pkgStats)
+	for i := range stats {
+		index[stats[i].Package] = &stats[i]
+	}
+	funcs := make(map[token.Position]bool)
+	for _, in := range rep.Inputs {
+		st := index[in.Package]
+		if st == nil {
+			continue
+		}
+		if !funcs[in.FuncPosition] {
+			funcs[in.FuncPosition] = true
+			st.Functions++
+		}
+		if in.Kind == usages.FuncReceiver {
+			st.Receivers++
+		} else {
+			st.Params++
+		}
+		if in.Suppressed {
+			st.Suppressed++
+		}
+		if in.Generated {
+			st.Generated++
+		}
+	}
+	for _, f := range reported {
+		st := index[f.Package]
+		if st == nil || f.Impls > 0 || !f.isUnused() {
+			continue
+		}
+		if f.Kind == usages.FuncReceiver {
+			st.UnusedReceivers++
+		} else {
+			st.UnusedParams++
+		}
+	}
+	return stats
+}
+
+// summary holds what is printed after all the reports: the findings,
+// if they aren't printed as they are found, and the statistics.
+var summary struct {
+	Findings []finding
+	Stats    []pkgStats
+}
+
+// printSummary prints the findings that haven't been printed yet, and
+// the statistics if requested, in the output format.
+func printSummary() error {
+	var total pkgStats
+	failed := 0
+	for _, st := range summary.Stats {
+		total.add(st)
+		if st.Failed {
+			failed++
+		}
+	}
+	total.Package = "total"
+
+	switch format {
+	case "html":
+		var stats []pkgStats
+		if showStats {
+			stats = append(summary.Stats, total)
+		}
+		return writeHTML(output, summary.Findings, stats)
+	case "json":
+		type jsonFinding struct {
+			Position string   `json:"position"`
+			Func     string   `json:"func"`
+			Kind     string   `json:"kind"`
+			Name     string   `json:"name"`
+			Message  string   `json:"message"`
+			Configs  []string `json:"configs,omitempty"`
+			Impls    int      `json:"implementations,omitempty"`
+		}
+		type jsonStats struct {
+			Packages       []pkgStats `json:"packages"`
+			Total          pkgStats   `json:"total"`
+			FailedPackages int        `json:"failedPackages"`
+		}
+		var out struct {
+			Findings []jsonFinding `json:"findings"`
+			Stats    *jsonStats    `json:"stats,omitempty"`
+		}
+		out.Findings = []jsonFinding{}
+		for _, f := range summary.Findings {
+			out.Findings = append(out.Findings, jsonFinding{
+				Position: f.FuncPosition.String(),
+				Func:     f.FuncName,
+				Kind:     f.Kind,
+				Name:     f.Name,
+				Message:  f.message(),
+				Configs:  f.Configs,
+				Impls:    f.Impls,
+			})
+		}
+		if showStats {
+			out.Stats = &jsonStats{Packages: summary.Stats, Total: total, FailedPackages: failed}
+			if out.Stats.Packages == nil {
+				out.Stats.Packages = []pkgStats{}
+			}
+		}
+		enc := json.NewEncoder(output)
+		enc.SetIndent("", "\t")
+		return enc.Encode(out)
+
+	default:
+		if !showStats {
+			return nil
+		}
+		fmt.Fprintln(output)
+		tw := tabwriter.NewWriter(output, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(tw, "package\tfunctions\treceivers\tparams\tunused receivers\tunused params\tsuppressed\tgenerated\t")
+		for _, st := range append(summary.Stats, total) {
+			name := st.Package
+			if st.Failed {
+				name += " (failed to type check)"
+			}
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t\n", name, st.Functions, st.Receivers, st.Params,
+				st.UnusedReceivers, st.UnusedParams, st.Suppressed, st.Generated)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintf(output, "%d of %d packages failed to type check\n", failed, len(summary.Stats))
+		return err
+	}
+}
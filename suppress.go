@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+)
+
+const directive = "//unusedargs:ignore"
+
+// isSuppressed reports whether the source has a directive that suppresses
+// reports for the receiver or param with the name, in the function at the
+// line (1-based). The directive can appear on the function's line or in
+// the comment lines directly above it.
+func isSuppressed(src []byte, line int, name string) bool {
+	lines := bytes.Split(src, []byte("\n"))
+	if line < 1 || line > len(lines) {
+		return false
+	}
+	if i := bytes.Index(lines[line-1], []byte(directive)); i >= 0 && directiveMatches(string(lines[line-1][i:]), name) {
+		return true
+	}
+	for i := line - 2; i >= 0; i-- {
+		l := strings.TrimSpace(string(lines[i]))
+		if !strings.HasPrefix(l, "//") {
+			break
+		}
+		if directiveMatches(l, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// directiveMatches reports whether the comment is a directive that
+// applies to the name.
+func directiveMatches(comment, name string) bool {
+	if !strings.HasPrefix(comment, directive) {
+		return false
+	}
+	rest := comment[len(directive):]
+	if rest != "" && rest[0] != ' ' && rest[0] != '\t' {
+		return false // e.g. "//unusedargs:ignored"
+	}
+	names := strings.Fields(rest)
+	if len(names) == 0 {
+		return true
+	}
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,13 @@
+package cgo
+
+// static int twice(int x) { return 2 * x; }
+import "C"
+
+func Twice(x int) int {
+	return int(C.twice(C.int(x)))
+}
+
+//export Callback
+func Callback(x C.int) {}
+
+func Unused(y int) {}
@@ -0,0 +1,48 @@
+package chandir
+
+func Produce(out chan int, n int) {
+	for i := 0; i < n; i++ {
+		out <- i
+	}
+	close(out)
+}
+
+func Consume(in chan int) int {
+	sum := 0
+	for v := range in {
+		sum += v
+	}
+	return sum
+}
+
+func First(in, done chan string) string {
+	select {
+	case s := <-in:
+		return s
+	case <-done:
+		return ""
+	}
+}
+
+func Forward(in chan int) {
+	Consume(in)
+}
+
+func Relay(ch chan int) {
+	Sink(ch)
+}
+
+func Sink(out chan<- int) {
+	out <- 1
+}
+
+func Both(ch chan int) {
+	ch <- <-ch
+}
+
+func Size(ch chan int) int {
+	if ch == nil {
+		return 0
+	}
+	return len(ch)
+}
@@ -0,0 +1,20 @@
+package ctx
+
+import (
+	"context"
+	"log"
+)
+
+func fetch(ctx context.Context, url string) error { _ = url; return nil }
+
+func Handle(ctx context.Context, id string) error {
+	return fetch(context.Background(), id)
+}
+
+func Poll(ctx context.Context) {
+	go func() { _ = fetch(context.TODO(), "x") }()
+}
+
+func Forward(ctx context.Context) error { return fetch(ctx, "") }
+
+func Trace(ctx context.Context) { log.Printf("tracing %v", ctx) }
@@ -0,0 +1,55 @@
+package dead
+
+const debug = false
+
+func Flagged(x, y int) int {
+	if debug {
+		println(x)
+	}
+	return y
+}
+
+func Never(s string) {
+	if false {
+		println(s)
+	}
+}
+
+func Discarded(a, b int) int {
+	c := a * 2
+	c = b
+	return c
+}
+
+func Deliberate(u int) {
+	_ = u
+}
+
+func Live(v int) {
+	if !debug {
+		println(v)
+	}
+}
+
+func Bounds(b []byte) {
+	_ = b[1]
+}
+
+func Deref(p *int) {
+	_ = *p
+}
+
+type S struct {
+	f int
+}
+
+func Field(s S) {
+	x := s.f
+	_ = x
+}
+
+func Indexed(b []byte, i int) {
+	c := b[i]
+	c = 1
+	println(c)
+}
@@ -0,0 +1,17 @@
+package deadgeneric
+
+const debug = false
+
+func Flagged(x, y int) int {
+	if debug {
+		println(x)
+	}
+	return y
+}
+
+func Max[T int | float64](a, b T) T {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,11 @@
+package dotted
+
+func Marshal(v interface{}, indent int) []byte {
+	return nil
+}
+
+type Decoder struct{}
+
+func (d *Decoder) Decode(v interface{}) error {
+	return nil
+}
@@ -0,0 +1,40 @@
+package fields
+
+type Base struct {
+	ID int
+}
+
+type Config struct {
+	Base
+	Addr    string
+	Port    int
+	Timeout int
+	Retries int
+	Debug   bool
+	Name    string
+	Tags    []string
+}
+
+func Dial(cfg *Config) string {
+	return cfg.Addr + ":" + string(rune(cfg.Port))
+}
+
+func ID(cfg Config) int {
+	return cfg.ID
+}
+
+func Many(cfg Config) int {
+	return cfg.Port + cfg.Timeout + cfg.Retries
+}
+
+func Whole(cfg *Config) {
+	Dial(cfg)
+}
+
+type Small struct {
+	A, B int
+}
+
+func First(s Small) int {
+	return s.A
+}
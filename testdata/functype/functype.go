@@ -0,0 +1,40 @@
+package functype
+
+type Handler func(path string, verbose bool) error
+
+type Middleware func(next Handler, cfg int) Handler
+
+type Hook func(name string)
+
+type Server struct {
+	Handler Handler
+	Hooks   map[string]Hook
+}
+
+func logging(next Handler, cfg int) Handler { return next }
+
+func register(m ...Middleware) {}
+
+var chain = []Middleware{
+	logging,
+	func(next Handler, _ int) Handler {
+		return func(path string, verbose bool) error { return next(path, false) }
+	},
+}
+
+func init() {
+	register(Middleware(logging))
+	var h Handler
+	h = func(path string, verbose bool) error { _ = verbose; return nil }
+	_ = Server{
+		Handler: h,
+		Hooks:   map[string]Hook{"start": func(name string) {}},
+	}
+}
+
+func wrap() Middleware {
+	return func(next Handler, cfg int) Handler { return next }
+}
+
+// Nothing is converted to Unused, so it isn't reported.
+type Unused func(x int)
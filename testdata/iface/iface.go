@@ -0,0 +1,32 @@
+package iface
+
+type Getter interface {
+	Get(key string, opts int) string
+}
+
+type Store interface {
+	Getter
+	Put(key, value string, version int)
+	Delete(string, bool)
+}
+
+type mem struct{}
+
+func (m mem) Get(key string, opts int) string { return key }
+
+func (m mem) Put(key, value string, _ int) { _, _ = key, value }
+
+func (mem) Delete(key string, _ bool) { _ = key }
+
+type disk struct{}
+
+func (d *disk) Get(key string, _ int) string { return key }
+
+func (d *disk) Put(key, _ string, version int) { _, _ = key, version }
+
+func (*disk) Delete(key string, force bool) { _ = key }
+
+// Nothing implements Lister, so it isn't reported.
+type Lister interface {
+	List(prefix string) []string
+}
@@ -0,0 +1,7 @@
+package a
+
+type Store interface {
+	Get(key string, opts int) string
+}
+
+type Hook func(name string, n int) int
@@ -0,0 +1,13 @@
+package b
+
+import "github.com/nishanths/unusedargs/testdata/ifacepkgs/a"
+
+type Mem struct{}
+
+func (Mem) Get(key string, opts int) string {
+	return key
+}
+
+var _ a.Store = Mem{}
+
+var _ a.Hook = func(name string, n int) int { return n }
@@ -0,0 +1,15 @@
+package c3
+
+type Store interface {
+	Get(key string, opts int) string
+}
+
+type Hook func(name string, n int) int
+
+type Mem struct{}
+
+func (Mem) Get(key string, opts int) string {
+	return key + string(rune(opts))
+}
+
+var _ Hook = func(name string, n int) int { return len(name) + n }
@@ -0,0 +1,31 @@
+package logging
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/nishanths/unusedargs/testdata/logging/logger"
+)
+
+var std = &logger.Logger{}
+
+func Save(id string, retries int) error {
+	log.Printf("saving %s", id)
+	return fmt.Errorf("%d retries left", retries)
+}
+
+func Load(key string, verbose bool) {
+	std.Infow("loading", "key", key)
+	if verbose {
+		println("verbose")
+	}
+}
+
+func Describe(name string) string {
+	return fmt.Sprintf("%s", strings.ToUpper(name))
+}
+
+func Notify(user string) {
+	log.Println(fmt.Sprintf("notifying %s", strings.ToUpper(user)))
+}
@@ -0,0 +1,3 @@
+package matrix
+
+func Common(x int) {}
@@ -0,0 +1,3 @@
+package matrix
+
+func platform(y int) { _ = y }
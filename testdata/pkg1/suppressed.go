@@ -0,0 +1,12 @@
+package pkg1
+
+//unusedargs:ignore
+func Suppressed(x int) {}
+
+// SuppressedByName has a directive for one of its params.
+//unusedargs:ignore y
+func SuppressedByName(x, y int) {}
+
+func SuppressedLiteral() {
+	_ = func(z int) {} //unusedargs:ignore
+}
@@ -0,0 +1,15 @@
+package pkg2
+
+type Exported struct{}
+
+type unexported struct{}
+
+func (e Exported) Method(x int) {}
+
+func (e *Exported) method(x int) {}
+
+func (u unexported) Method(x int) {}
+
+func Func(x int) {
+	_ = func(y int) {}
+}
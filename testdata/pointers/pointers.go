@@ -0,0 +1,96 @@
+package pointers
+
+import "sync"
+
+type Point struct {
+	X, Y int
+}
+
+func Sum(p *Point) int {
+	return p.X + p.Y
+}
+
+func Copy(p *Point) Point {
+	return *p
+}
+
+func Move(p *Point) {
+	p.X++
+}
+
+func Same(p, q *Point) bool {
+	return p == q
+}
+
+func Addr(p *Point) *int {
+	return &p.X
+}
+
+func (p *Point) Len() int {
+	return p.X*p.X + p.Y*p.Y
+}
+
+func (p *Point) Scale(k int) {
+	p.X *= k
+	p.Y *= k
+}
+
+func (p Point) Reset() {
+	p.X = 0
+	p.Y = 0
+}
+
+func (p Point) WithX(x int) Point {
+	p.X = x
+	return p
+}
+
+type Size struct {
+	W, H int
+}
+
+func (s *Size) Area() int {
+	return s.W * s.H
+}
+
+type Big struct {
+	Data [100]int
+}
+
+func First(b *Big) int {
+	return b.Data[0]
+}
+
+type Counter struct {
+	mu sync.Mutex
+	n  int
+}
+
+func Get(c *Counter) int {
+	return c.n
+}
+
+type List struct {
+	Next *List
+	Val  int
+}
+
+func (l List) SetNext(n int) {
+	l.Next.Val = n
+}
+
+type Pair[T any] struct {
+	A, B T
+}
+
+func AddPair[T int | float64](p *Pair[T]) T {
+	return p.A + p.B
+}
+
+func (p *Pair[T]) Left() T {
+	return p.A
+}
+
+func AddInts(p *Pair[int]) int {
+	return p.A + p.B
+}
@@ -0,0 +1,3 @@
+package dep
+
+func Win() int { return 2 }
@@ -0,0 +1,7 @@
+package w
+
+import "github.com/nishanths/unusedargs/testdata/tagdeps/dep"
+
+func Handle(a, b int) int {
+	return dep.Win() + a
+}
@@ -0,0 +1,7 @@
+package xtest_test
+
+import "github.com/nishanths/unusedargs/testdata/xtest"
+
+func use(t xtest.T, u int) {
+	t.M()
+}
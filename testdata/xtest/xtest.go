@@ -0,0 +1,5 @@
+package xtest
+
+type T int
+
+func (T) M() {}
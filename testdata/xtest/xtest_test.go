@@ -0,0 +1,3 @@
+package xtest
+
+func helper(v int) {}
@@ -10,7 +10,32 @@
 //
 // The exit code is 0 if there were no unused receivers or params. It is
 // 1 if there was an unused receiver or param, or if a parser error occurred.
-// Generated files are not checked.
+// Generated files are not checked, nor are functions exported to C with
+// a cgo //export directive, since C callers fix their signatures.
+//
+// Reports for a function can be suppressed with an "//unusedargs:ignore"
+// directive on the line of the function or in the comment lines directly
+// above it. The directive may be followed by the names of the receivers
+// and params to suppress; otherwise all of the function's reports are
+// suppressed.
+//
+//   //unusedargs:ignore state
+//   func authURL(clientID, code int, state string) string {
+//
+// The lsp subcommand runs a Language Server Protocol server on standard
+// input and output. It publishes diagnostics for open documents, and
+// offers code actions to rename an unused param to _, to drop an unused
+// receiver's name, or to add a suppression directive.
+//
+// The uses subcommand prints each receiver and param of a function with
+// the positions of its uses. The function is given as pkg.Func,
+// pkg.Type.Method, or file.go:line.
+//
+//   $ unusedargs uses ./auth.authURL
+//   auth/main.go:8:6: authURL
+//   	param clientID: 1 use
+//   		auth/main.go:9:65
+//   	...
 //
 // Methods satisfying an interface
 //
@@ -43,12 +68,17 @@ import (
 	"flag"
 	"fmt"
 	"go/build"
+	"go/token"
+	"go/types"
 	"io"
-	"io/ioutil"
 	"log"
 	"os"
+	"path"
 	"path/filepath"
+	"runtime"
 	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/nishanths/unusedargs/usages"
 )
@@ -58,9 +88,86 @@ const help = `Usage:
   unusedarg [flags] [packages]
   unusedarg [flags] [directories] # where a '/...' suffix includes all sub-directories
   unusedarg [flags] [files]
+  unusedarg cache clean # removes all cached results
+  unusedarg lsp # runs a Language Server Protocol server on stdin/stdout
+  unusedarg uses pkg.Func|pkg.Type.Method|file.go:line # prints the uses of each receiver and param
+  go vet -vettool=$(which unusedargs) [packages]
 
 Flags:
   -h, -help    Print usage information and exit.
+  -fields      Also report params of struct or pointer to struct type, with
+               at least 4 fields, whose function only uses at most this
+               fraction of the fields, such as 0.25, listing the fields
+               used (default 0, off).
+  -fix         With -chandir, rewrite the signatures of the reported
+               functions to the narrower channel types, where each such
+               param is declared with its own type (default false).
+  -format      Output format: text, json, or html (default text). The json
+               format prints a single object with the findings and, if
+               -stats is set, the statistics. The html format prints a
+               self-contained page showing the source of each function.
+  -config      Build configuration to analyse, as [GOOS/GOARCH][,tag...].
+               Can be repeated; a receiver or param is then reported only
+               if it is unused in every configuration that includes its
+               file (default: the host configuration). Dependencies are
+               imported as built for each configuration.
+  -cache       Whether to reuse results for unchanged packages from previous
+               runs: on or off (default on).
+  -kind        Only report unused receivers, params, or context.Context
+               params: receiver, param, or context (default all). Unused
+               contexts are reported with the calls in the function that
+               pass context.Background() or context.TODO() instead.
+  -json        With go vet, print diagnostics as JSON (default false).
+  -interfaces  Also report params of interface methods that are unused by
+               every implementation in the checked packages. Types are
+               matched against interfaces declared in their own package and
+               in the packages it imports (default false).
+  -deadcode    Also report receivers and params whose only uses are in
+               code that is unreachable, treating branches on constants
+               such as "if debug" as fixed, or in computations whose
+               results are unused. Assigning to _ counts as a use
+               (default false).
+  -functypes   Also report params of named func types that are unused by
+               every function converted or assigned to the type in the
+               checked packages (default false).
+  -importer    How to import dependencies for type checking: gc (installed
+               export data), golist (export data from 'go list -export'),
+               source, gccgo, or auto, which tries gc, golist, and source
+               in turn (default auto).
+  -logging     Also report receivers and params that are only used in
+               arguments to logging functions: those in log and
+               log/slog, and those named by -logfuncs (default false).
+  -logfuncs    Comma-separated full names of more logging functions, as
+               pkg/path.Func or (*pkg/path.Type).Method.
+  -chandir     Also report params of type chan T that are only sent on or
+               only received from, suggesting chan<- T or <-chan T.
+               Passing the param to a function taking a channel of one
+               direction counts as that use (default false).
+  -j           Number of packages to analyse concurrently (default GOMAXPROCS).
+  -visibility  Only report functions that are exported or unexported: a
+               function is exported if its name is, and, for a method, if
+               its receiver type's name is (default both).
+  -watch       Keep running, re-analysing packages whenever their .go files
+               change (default false).
+  -tests       Also check test files, type checking internal and external
+               test packages the way go test does (default true).
+  -narrow      Also report params of concrete types that are only used to
+               call methods of an interface, suggesting the interface.
+               Interfaces are taken from the package, its imports, and
+               the io, fmt, and sort packages (default false).
+  -pointers    Also report pointer receivers and params to values of at
+               most 64 bytes that are only read through, and could be
+               passed by value, and value receivers whose fields are
+               assigned to, which the caller doesn't see (default false).
+  -overlay     JSON file in the format of go build -overlay, replacing the
+               contents of files.
+  -stdin       Check the contents of standard input as the file named by
+               -stdin-filename, within its package, and report only in
+               that file (default false).
+  -staged      Check the packages of the .go files staged in the git index,
+               using the staged contents of those files, and report only
+               in those files. For use in pre-commit hooks (default false).
+  -stats       Print statistics per package after the findings (default false).
   -strict      Fail if one of the supplied files could not be parsed or 
                type checked, instead of skipping the files (default false).
 `
@@ -71,21 +178,165 @@ func usage() {
 }
 
 var strict bool
+var jobs int
+var cacheMode string
+var watchMode bool
+var tests = true
+var importerName = usages.ImporterAuto
+var format = "text"
+var showStats bool
+var kindFilter string
+var visibilityFilter string
+var vetJSON bool
+var vetVersion string
+var vetFlags bool
+var staged bool
+var stdin bool
+var stdinFilename string
+var overlayFile string
+var checkDeadCode bool
 var output io.Writer = os.Stdout // where to write reports
 var exitCode int
 
+// defineFlags defines the command's flags in fs. They are documented
+// in help.
+func defineFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&strict, "strict", false, "")
+	fs.IntVar(&jobs, "j", runtime.GOMAXPROCS(0), "")
+	fs.StringVar(&cacheMode, "cache", "on", "")
+	fs.BoolVar(&watchMode, "watch", false, "")
+	fs.BoolVar(&tests, "tests", true, "")
+	fs.StringVar(&importerName, "importer", usages.ImporterAuto, "")
+	fs.Var(&configs, "config", "")
+	fs.StringVar(&format, "format", "text", "")
+	fs.BoolVar(&showStats, "stats", false, "")
+	fs.StringVar(&kindFilter, "kind", "", "")
+	fs.StringVar(&visibilityFilter, "visibility", "", "")
+	fs.BoolVar(&vetJSON, "json", false, "")
+	fs.StringVar(&vetVersion, "V", "", "")
+	fs.BoolVar(&vetFlags, "flags", false, "")
+	fs.BoolVar(&staged, "staged", false, "")
+	fs.BoolVar(&stdin, "stdin", false, "")
+	fs.StringVar(&stdinFilename, "stdin-filename", "", "")
+	fs.StringVar(&overlayFile, "overlay", "", "")
+	fs.BoolVar(&checkInterfaces, "interfaces", false, "")
+	fs.BoolVar(&checkFuncTypes, "functypes", false, "")
+	fs.BoolVar(&checkLogging, "logging", false, "")
+	fs.BoolVar(&checkDeadCode, "deadcode", false, "")
+	fs.BoolVar(&checkNarrow, "narrow", false, "")
+	fs.Float64Var(&fieldsFraction, "fields", 0, "")
+	fs.BoolVar(&checkChanDir, "chandir", false, "")
+	fs.BoolVar(&fixChanDir, "fix", false, "")
+	fs.BoolVar(&checkPointers, "pointers", false, "")
+	fs.StringVar(&extraLogFuncs, "logfuncs", "", "")
+}
+
 func main() {
 	log.SetFlags(0)
 	log.SetPrefix("unusedarg: ")
 
-	flag.BoolVar(&strict, "strict", false, "")
+	defineFlags(flag.CommandLine)
 	flag.Usage = usage
 	flag.Parse()
 
+	// Handle the queries go vet makes of a -vettool.
+	if vetVersion != "" {
+		if err := printVetVersion(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if vetFlags {
+		if err := printVetFlags(os.Stdout, flag.CommandLine); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if jobs < 1 || usages.NewImporter(importerName, nil, token.NewFileSet()) == nil {
+		usage()
+	}
+	if format != "text" && format != "json" && format != "html" {
+		usage()
+	}
+	switch kindFilter {
+	case "", usages.FuncReceiver, usages.FuncParam, kindContext:
+	default:
+		usage()
+	}
+	switch visibilityFilter {
+	case "", "exported", "unexported":
+	default:
+		usage()
+	}
+
 	args := flag.Args()
 
-	if len(args) == 0 {
-		handleDir(".")
+	if len(args) == 1 && strings.HasSuffix(args[0], ".cfg") {
+		os.Exit(runVet(args[0], os.Stdout, os.Stderr))
+	}
+
+	if len(args) == 1 && args[0] == "lsp" {
+		if err := serveLSP(os.Stdin, os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if len(args) == 2 && args[0] == "cache" && args[1] == "clean" {
+		if err := cache.clean(); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	switch cacheMode {
+	case "on":
+		cache = openCache()
+	case "off":
+	default:
+		usage()
+	}
+
+	if overlayFile != "" {
+		if err := loadOverlay(overlayFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if len(args) == 2 && args[0] == "uses" {
+		if err := printUses(os.Stdout, args[1]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	var tasks []task
+	var watched [][]string // paths to watch for each task
+	if stdin {
+		if len(args) > 0 || stdinFilename == "" || staged || watchMode || fixChanDir {
+			usage()
+		}
+		dir, err := addStdin(os.Stdin, stdinFilename)
+		if err != nil {
+			log.Fatal(err)
+		}
+		tasks = append(tasks, forConfigs(func(ctx *build.Context) *report { return checkDir(ctx, dir) }))
+	} else if staged {
+		if len(args) > 0 || watchMode || fixChanDir {
+			usage()
+		}
+		dirs, err := addStaged()
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, dir := range dirs {
+			dir := dir
+			tasks = append(tasks, forConfigs(func(ctx *build.Context) *report { return checkDir(ctx, dir) }))
+		}
+	} else if len(args) == 0 {
+		tasks = append(tasks, forConfigs(func(ctx *build.Context) *report { return checkDir(ctx, ".") }))
+		watched = append(watched, []string{"."})
 	} else {
 		dirsRun, filesRun, pkgsRun, results := classifyArgs(args)
 		// TODO(nishanth): This kind of return value feels gross.
@@ -95,13 +346,18 @@ func main() {
 		switch {
 		case dirsRun == 1:
 			for _, dir := range results {
-				handleDir(dir)
+				dir := dir
+				tasks = append(tasks, forConfigs(func(ctx *build.Context) *report { return checkDir(ctx, dir) }))
+				watched = append(watched, []string{dir})
 			}
 		case filesRun == 1:
-			handleFiles(results)
+			tasks = append(tasks, func() *report { return checkFiles(results) })
+			watched = append(watched, results)
 		case pkgsRun == 1:
 			for _, pkg := range importPaths(results) {
-				handlePkg(pkg)
+				pkg := pkg
+				tasks = append(tasks, forConfigs(func(ctx *build.Context) *report { return checkPkg(ctx, pkg) }))
+				watched = append(watched, []string{pkgDir(pkg)})
 			}
 		default:
 			// cannot happen
@@ -109,6 +365,22 @@ func main() {
 		}
 	}
 
+	if fixChanDir && (!checkChanDir || watchMode || overlayFile != "") {
+		usage()
+	}
+	if watchMode {
+		watch(tasks, watched, watchInterval)
+	}
+	runTasks(tasks, jobs, handleReport)
+	if checkInterfaces || checkFuncTypes {
+		reportAbstract()
+	}
+	if err := applyChanFixes(); err != nil {
+		log.Fatal(err)
+	}
+	if err := printSummary(); err != nil {
+		log.Fatal(err)
+	}
 	os.Exit(exitCode)
 }
 
@@ -138,59 +410,279 @@ func isIgnorable(err error) bool {
 	return false
 }
 
-func handleDir(p string) {
-	pkg, err := build.ImportDir(p, 0)
+// A report is the outcome of checking a single target: a directory,
+// a package, or the list of files named on the command line.
+// Reports are built by worker goroutines and printed by handleReport
+// in the order the targets were given.
+type report struct {
+	Findings []finding       // unused receivers and params, in file order
+	Files    []string        // files that were checked, sorted
+	Skipped  []string        // files that could not be read
+	Partial  []string        // packages that failed to type check
+	NoDead   []string        // packages -deadcode couldn't analyse
+	Stats    []pkgStats      // packages, for statistics, counted by countStats
+	Inputs   []analysedInput // receivers and params analysed, for statistics
+	Err      error           `json:"-"` // fatal error for the target, if any
+
+	// Interface methods and named func types, and their implementations,
+	// if -interfaces or -functypes is set.
+	Abstract []abstractFunc
+	Impls    []concreteFunc
+}
+
+// A finding is an unused receiver or param.
+type finding struct {
+	Package      string         // package, as labelled in statistics
+	Position     token.Position // position of the receiver or param
+	FuncPosition token.Position
+	FuncName     string         // name of function, or "func" if function literal
+	FuncStart    int            // byte offset of the start of the function in its file
+	FuncEnd      int            // byte offset of the end of the function in its file
+	Kind         string         // usages.FuncReceiver, usages.FuncParam, or kindContext
+	Name         string         // name of the receiver or param
+	Exported     bool           // function is part of the package's exported API
+	Others       []inputUses    // uses of the function's other receivers and params
+	Configs      []string       // build configurations it is unused in, if -config was used
+	Impls        int            // for an abstractFunc, the number of implementations, which all ignore the param
+	Fresh        []freshContext // for an unused context, calls passing a fresh context instead
+	Shadow       token.Position // declaration shadowing it where its name appears to be used, if any
+	LogOnly      bool           // it is used, but only in arguments to logging functions
+	Dead         bool           // it is used, but only in dead code
+	Narrow       string         // an interface the param could have as its type instead, if -narrow is set
+	Methods      []string       // methods called on the param, for Narrow
+	Fields       []string       // the few fields used of the param's struct, if -fields is set
+	NumFields    int            // number of fields of the struct, for Fields
+	Chan         string         // a narrower channel type the param could have, if -chandir is set
+	ChanFix      int            // byte offset at which to insert "<-" to narrow the param to Chan, or 0
+	ByValue      bool           // it is a pointer only used to read a small value, if -pointers is set
+	LostWrite    token.Position // assignment to a field of the value receiver, if -pointers is set
+}
+
+// inputUses are the uses of a receiver or param.
+type inputUses struct {
+	Kind string
+	Name string
+	Uses []token.Position
+}
+
+func (f finding) String() string {
+	s := fmt.Sprintf("%s: %s", f.FuncPosition, f.message())
+	if len(f.Configs) > 0 {
+		s += " [" + strings.Join(f.Configs, " ") + "]"
+	}
+	return s
+}
+
+// isUnused reports whether the receiver or param is unused, rather than
+// used in a way that is reported, such as only in logging.
+func (f finding) isUnused() bool {
+	return !f.LogOnly && !f.Dead && f.Narrow == "" && len(f.Fields) == 0 && f.Chan == "" && !f.ByValue && !f.LostWrite.IsValid()
+}
+
+// message describes the finding, without its position.
+func (f finding) message() string {
+	switch {
+	case f.Impls == 1:
+		return fmt.Sprintf("%s has %s %s unused by its only implementation", f.FuncName, f.Kind, f.Name)
+	case f.Impls > 1:
+		return fmt.Sprintf("%s has %s %s unused by all %d implementations", f.FuncName, f.Kind, f.Name, f.Impls)
+	case len(f.Fresh) > 0:
+		var calls []string
+		for _, c := range f.Fresh {
+			calls = append(calls, fmt.Sprintf("%s at %d:%d", c.Call, c.Position.Line, c.Position.Column))
+		}
+		return fmt.Sprintf("%s has unused %s %s; forward it instead of %s", f.FuncName, f.Kind, f.Name, strings.Join(calls, ", "))
+	case f.Dead:
+		return fmt.Sprintf("%s has %s %s only used in dead code", f.FuncName, f.Kind, f.Name)
+	case f.LogOnly:
+		return fmt.Sprintf("%s has %s %s only used in logging", f.FuncName, f.Kind, f.Name)
+	case f.Narrow != "":
+		return fmt.Sprintf("%s has %s %s that only uses %s; consider %s", f.FuncName, f.Kind, f.Name, strings.Join(f.Methods, ", "), f.Narrow)
+	case len(f.Fields) > 0:
+		return fmt.Sprintf("%s has %s %s that only uses %d of %d fields: %s", f.FuncName, f.Kind, f.Name, len(f.Fields), f.NumFields, strings.Join(f.Fields, ", "))
+	case f.Chan != "":
+		return fmt.Sprintf("%s has %s %s that is only %s; consider %s", f.FuncName, f.Kind, f.Name, chanUse(f.Chan), f.Chan)
+	case f.ByValue:
+		return fmt.Sprintf("%s has %s %s that could be passed by value", f.FuncName, f.Kind, f.Name)
+	case f.LostWrite.IsValid():
+		return fmt.Sprintf("%s has value %s %s assigned to at %d:%d, which is lost when it returns", f.FuncName, f.Kind, f.Name, f.LostWrite.Line, f.LostWrite.Column)
+	case f.Shadow.IsValid():
+		return fmt.Sprintf("%s %s %s is shadowed at %d:%d and never used", f.FuncName, f.Kind, f.Name, f.Shadow.Line, f.Shadow.Column)
+	}
+	return fmt.Sprintf("%s has unused %s %s", f.FuncName, f.Kind, f.Name)
+}
+
+func checkDir(ctx *build.Context, p string) *report {
+	pkg, err := ctx.ImportDir(p, 0)
 	if err != nil {
 		if !isIgnorable(err) {
-			log.Fatal(err)
+			return &report{Err: err}
 		}
-		return
+		return &report{}
 	}
-	handleImportedPkg(pkg)
+	return checkImportedPkg(ctx, pkg)
 }
 
-func handlePkg(p string) {
-	pkg, err := build.Import(p, ".", 0)
+func checkPkg(ctx *build.Context, p string) *report {
+	pkg, err := ctx.Import(p, ".", 0)
 	if err != nil {
 		if !isIgnorable(err) {
-			log.Fatal(err)
+			return &report{Err: err}
 		}
-		return
+		return &report{}
 	}
-	handleImportedPkg(pkg)
+	return checkImportedPkg(ctx, pkg)
 }
 
-func handleImportedPkg(pkg *build.Package) {
+// pkgFiles returns the paths of the package's files to check.
+func pkgFiles(pkg *build.Package) []string {
 	var files []string
 	files = append(files, pkg.GoFiles...)
 	files = append(files, pkg.CgoFiles...)
-	files = append(files, pkg.TestGoFiles...)
-	files = append(files, pkg.XTestGoFiles...)
+	if tests {
+		files = append(files, pkg.TestGoFiles...)
+		files = append(files, pkg.XTestGoFiles...)
+	}
 	if pkg.Dir != "." {
 		for i, f := range files {
 			files[i] = filepath.Join(pkg.Dir, f)
 		}
 	}
-	handleFiles(files)
+	return files
+}
+
+func checkImportedPkg(ctx *build.Context, pkg *build.Package) *report {
+	contents, skipped, err := readFiles(pkgFiles(pkg))
+	if err != nil {
+		return &report{Err: err}
+	}
+	if len(skipped) > 0 {
+		// The package's sources are incomplete; don't use the cache.
+		return analyse(importPath(pkg), ctx.GOARCH, importerFor(ctx), contents, skipped)
+	}
+
+	key := cacheKey(ctx, pkg, contents)
+	if rep := cache.get(key); rep != nil {
+		return rep
+	}
+	rep := analyse(importPath(pkg), ctx.GOARCH, importerFor(ctx), contents, nil)
+	if rep.Err == nil {
+		cache.put(key, rep)
+	}
+	return rep
+}
+
+func checkFiles(files []string) *report {
+	if !tests {
+		var nontest []string
+		for _, name := range files {
+			if !strings.HasSuffix(name, "_test.go") {
+				nontest = append(nontest, name)
+			}
+		}
+		files = nontest
+	}
+	contents, skipped, err := readFiles(files)
+	if err != nil {
+		return &report{Err: err}
+	}
+	return analyse("", buildContext.GOARCH, importerFor(&buildContext), contents, skipped)
+}
+
+// importPath returns the import path of the package, or the empty
+// string if it is not known.
+func importPath(pkg *build.Package) string {
+	if pkg.ImportPath == "" || build.IsLocalImport(pkg.ImportPath) {
+		return ""
+	}
+	return pkg.ImportPath
+}
+
+// dirImportPath returns the import path of the package in dir, found
+// from the go.mod file of its module or from its GOPATH workspace, or
+// else its absolute directory, which can't be mistaken for an import
+// path.
+func dirImportPath(dir string) string {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return dir
+	}
+	for d := abs; ; d = filepath.Dir(d) {
+		if b, err := readFile(filepath.Join(d, "go.mod")); err == nil {
+			if mod := modulePath(b); mod != "" {
+				rel, _ := filepath.Rel(d, abs)
+				return path.Join(mod, filepath.ToSlash(rel))
+			}
+			break
+		}
+		if filepath.Dir(d) == d {
+			break
+		}
+	}
+	for _, root := range filepath.SplitList(buildContext.GOPATH) {
+		rel, err := filepath.Rel(filepath.Join(root, "src"), abs)
+		if err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return abs
 }
 
-func handleFiles(files []string) {
-	contents := make(map[string][]byte)
+// modulePath returns the module path declared in the go.mod file, or
+// the empty string if there is none.
+func modulePath(gomod []byte) string {
+	for _, line := range strings.Split(string(gomod), "\n") {
+		f := strings.Fields(line)
+		if len(f) >= 2 && f[0] == "module" {
+			if p, err := strconv.Unquote(f[1]); err == nil {
+				return p
+			}
+			return f[1]
+		}
+	}
+	return ""
+}
+
+// readFiles reads the named files. Files that could not be read are
+// returned in skipped, unless strict is set, in which case the first
+// such error is returned.
+func readFiles(files []string) (contents map[string][]byte, skipped []string, err error) {
+	contents = make(map[string][]byte)
 	for _, name := range files {
-		b, err := ioutil.ReadFile(name)
+		b, err := readFile(name)
 		if err != nil {
 			if strict {
-				log.Fatal(err)
+				return nil, nil, err
 			}
-			fmt.Fprintf(os.Stderr, "skipping: %s\n", err)
+			skipped = append(skipped, err.Error())
 			continue
 		}
 		contents[name] = b
 	}
+	return contents, skipped, nil
+}
+
+// analyse finds the unused receivers and params in the files.
+// contents is a map from the file's path to its contents. importPath
+// is the import path of the package, if known. The files are parsed
+// into the file set of imp, which imports their dependencies.
+func analyse(importPath, goarch string, imp *sharedImporter, contents map[string][]byte, skipped []string) *report {
+	rep := &report{Skipped: skipped}
+	for name := range contents {
+		rep.Files = append(rep.Files, name)
+	}
+	sort.Strings(rep.Files)
 
-	results, _, warns, err := usages.Find(contents)
+	fset := imp.fset
+	config := &usages.Config{
+		Fset:       fset,
+		Importer:   imp,
+		ImportPath: importPath,
+		DeadCode:   checkDeadCode,
+	}
+	results, infos, warns, err := config.Find(contents)
 	if err != nil {
-		log.Fatal(err)
+		return &report{Err: err}
 	}
 
 	// Sort warnings.
@@ -198,21 +690,14 @@ func handleFiles(files []string) {
 	for pkg := range warns {
 		warnsOrder = append(warnsOrder, pkg)
 	}
-	sort.Slice(warnsOrder, func(i, j int) bool {
-		return warnsOrder[i] < warnsOrder[j]
-	})
+	sort.Strings(warnsOrder)
 
-	// Print warnings (once per package).
-	printedWarns := make(map[string]bool)
+	// Record warnings (once per package).
 	for _, pkg := range warnsOrder {
 		if strict {
-			log.Fatal(warns[pkg][0]) // there will be at least one if the package was in the warns map.
-		}
-		if printedWarns[pkg] {
-			continue // already printed
+			return &report{Err: warns[pkg][0]} // there will be at least one if the package was in the warns map.
 		}
-		printedWarns[pkg] = true
-		fmt.Fprintf(os.Stderr, "failed to type check package %s: results may be partial\n", pkg)
+		rep.Partial = append(rep.Partial, pkg)
 	}
 
 	// Sort results packages.
@@ -220,25 +705,208 @@ func handleFiles(files []string) {
 	for pkg := range results {
 		resultsOrder = append(resultsOrder, pkg)
 	}
-	sort.Slice(resultsOrder, func(i, j int) bool {
-		return resultsOrder[i] < resultsOrder[j]
-	})
+	sort.Strings(resultsOrder)
 
-	// Print results.
+	sizes := types.SizesFor("gc", goarch)
+	if sizes == nil {
+		sizes = types.SizesFor("gc", "amd64")
+	}
+
+	var logging map[string]bool
+	if checkLogging {
+		logging = logFuncs()
+	}
+
+	// Identify the package across reports, to match interfaces and
+	// func types to their implementations in other packages.
+	pkgPath := importPath
+	if pkgPath == "" && len(rep.Files) > 0 && (checkInterfaces || checkFuncTypes) {
+		pkgPath = dirImportPath(filepath.Dir(rep.Files[0]))
+	}
+
+	// Record findings and statistics.
 	for _, pkg := range resultsOrder {
+		st := pkgStats{Package: pkgLabel(importPath, pkg, results[pkg])} // counted by countStats
+		if rs := results[pkg]; len(rs) > 0 && rs[0].DeadSkipped {
+			rep.NoDead = append(rep.NoDead, pkg)
+		}
+		_, st.Failed = warns[pkg]
+		funcs := make(map[token.Position][]usages.Result)
 		for _, r := range results[pkg] {
-			if len(r.Uses) > 0 {
+			funcs[r.FuncPosition] = append(funcs[r.FuncPosition], r)
+		}
+
+		var cands []*types.TypeName // interfaces to suggest, if -narrow is set
+		if checkNarrow {
+			for _, r := range results[pkg] {
+				if obj := infos[pkg].Defs[r.Ident]; obj != nil {
+					cands = interfaceCandidates(obj.Pkg(), imp)
+					break
+				}
+			}
+		}
+
+		for _, r := range results[pkg] {
+			rep.Inputs = append(rep.Inputs, analysedInput{Package: st.Package, FuncPosition: r.FuncPosition, Position: r.Position, Kind: r.Kind})
+			in := &rep.Inputs[len(rep.Inputs)-1]
+
+			logOnly := false
+			var narrow string
+			var methods, fields []string
+			var numFields int
+			var chanType string
+			var chanFix token.Pos
+			byValue := false
+			var lost token.Pos
+			if len(r.Uses) > 0 && !r.Dead {
+				if checkLogging && onlyLogged(r.Func, r.Uses, infos[pkg], logging) {
+					logOnly = true
+				} else if r.Kind == usages.FuncParam {
+					obj := infos[pkg].Defs[r.Ident]
+					if checkNarrow {
+						narrow, methods = narrowerInterface(r.Func, obj, r.Uses, infos[pkg], cands)
+					}
+					if fieldsFraction > 0 {
+						fields, numFields = usedFields(r.Func, obj, r.Uses, infos[pkg])
+					}
+					if checkChanDir {
+						chanType, chanFix = narrowerChan(r.Func, r.Ident, r.Uses, infos[pkg])
+					}
+				}
+				if checkPointers && !logOnly {
+					obj := infos[pkg].Defs[r.Ident]
+					byValue = passableByValue(r.Func, obj, r.Uses, infos[pkg], sizes)
+					if r.Kind == usages.FuncReceiver {
+						lost = lostWrite(r.Func, obj, r.Uses, infos[pkg])
+					}
+				}
+			}
+			// Otherwise used, but could have a narrower type, or be
+			// passed differently.
+			unused := narrow == "" && fields == nil && chanType == "" && !byValue && !lost.IsValid() // or only logged or dead
+			if len(r.Uses) > 0 && !r.Dead && !logOnly && unused {
 				continue // has uses
 			}
+			if r.CgoExport {
+				in.Suppressed = len(r.Uses) == 0
+				continue // signature is constrained by C callers
+			}
 			if isGenerated(contents[r.Position.Filename]) {
+				in.Generated = len(r.Uses) == 0
 				continue // no warnings on generated files
 			}
+			if isSuppressed(contents[r.Position.Filename], r.FuncPosition.Line, r.Ident.Name) {
+				in.Suppressed = len(r.Uses) == 0
+				continue // suppressed by directive
+			}
 			name := r.FuncName
 			if name == "" {
 				name = "func"
 			}
-			exitCode = 1
-			fmt.Fprintf(output, "%s: %s has unused %s %s\n", r.FuncPosition, name, r.Kind, r.Ident.Name)
+			var others []inputUses
+			for _, o := range funcs[r.FuncPosition] {
+				if o.Position == r.Position {
+					continue
+				}
+				in := inputUses{Kind: o.Kind, Name: o.Ident.Name}
+				for _, u := range o.Uses {
+					in.Uses = append(in.Uses, fset.Position(u.Pos()))
+				}
+				others = append(others, in)
+			}
+			f := finding{
+				Package:      st.Package,
+				Position:     r.Position,
+				FuncPosition: r.FuncPosition,
+				FuncName:     name,
+				FuncStart:    fset.Position(r.Func.Pos()).Offset,
+				FuncEnd:      fset.Position(r.Func.End()).Offset,
+				Kind:         r.Kind,
+				Name:         r.Ident.Name,
+				Exported:     isExported(r.Func),
+				Others:       others,
+				LogOnly:      logOnly,
+				Dead:         r.Dead,
+				Narrow:       narrow,
+				Methods:      methods,
+				Fields:       fields,
+				NumFields:    numFields,
+				Chan:         chanType,
+				ByValue:      byValue,
+			}
+			if lost.IsValid() {
+				f.LostWrite = fset.Position(lost)
+			}
+			if chanFix.IsValid() {
+				f.ChanFix = fset.Position(chanFix).Offset
+			}
+			if obj := infos[pkg].Defs[r.Ident]; len(r.Uses) == 0 && r.Kind == usages.FuncParam && obj != nil && isContext(obj.Type()) {
+				f.Kind = kindContext
+				f.Fresh = freshContexts(r.Func, infos[pkg], fset)
+			}
+			if pos := shadowedAt(r.Func, r.Ident, infos[pkg]); len(r.Uses) == 0 && pos.IsValid() {
+				f.Shadow = fset.Position(pos)
+			}
+			rep.Findings = append(rep.Findings, f)
+		}
+		rep.Stats = append(rep.Stats, st)
+		if checkInterfaces {
+			recordInterfaces(rep, fset, infos[pkg], st.Package, pkgPath, contents)
+		}
+		if checkFuncTypes {
+			recordFuncTypes(rep, fset, infos[pkg], st.Package, pkgPath, contents)
+		}
+	}
+	return rep
+}
+
+// pkgLabel returns the name to use for the package in statistics:
+// its import path if known, or else its directory and name.
+func pkgLabel(importPath, pkg string, results []usages.Result) string {
+	if importPath != "" {
+		if strings.HasSuffix(pkg, "_test") {
+			return importPath + "_test"
+		}
+		return importPath
+	}
+	if len(results) > 0 {
+		return filepath.Dir(results[0].Position.Filename) + " (" + pkg + ")"
+	}
+	return pkg
+}
+
+// handleReport prints a report, or records it to be printed by
+// printSummary, and updates the exit code.
+func handleReport(rep *report) {
+	if rep.Err != nil {
+		exitCode = 1
+		log.Print(rep.Err)
+		return
+	}
+	for _, s := range rep.Skipped {
+		fmt.Fprintf(os.Stderr, "skipping: %s\n", s)
+	}
+	for _, pkg := range rep.Partial {
+		fmt.Fprintf(os.Stderr, "failed to type check package %s: results may be partial\n", pkg)
+	}
+	for _, pkg := range rep.NoDead {
+		fmt.Fprintf(os.Stderr, "-deadcode skipped for package %s: it can't be built in SSA form\n", pkg)
+	}
+	var reported []finding
+	for _, f := range rep.Findings {
+		if !matchesFilters(f) {
+			continue
+		}
+		exitCode = 1
+		addChanFix(f)
+		reported = append(reported, f)
+		if format == "text" {
+			fmt.Fprintln(output, f)
+		} else {
+			summary.Findings = append(summary.Findings, f)
 		}
 	}
+	summary.Stats = append(summary.Stats, countStats(rep, reported)...)
+	abstract.Funcs = append(abstract.Funcs, rep.Abstract...)
+	abstract.Impls = append(abstract.Impls, rep.Impls...)
 }
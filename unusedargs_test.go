@@ -2,33 +2,54 @@ package main
 
 import (
 	"bytes"
+	"fmt"
+	"go/build"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/nishanths/unusedargs/usages"
 )
 
+// captureOutput sets output to a new buffer for the rest of the test,
+// and restores output, exitCode, and the summary when it ends, since
+// handleReport updates them.
+func captureOutput(t *testing.T) *bytes.Buffer {
+	w, code, sum := output, exitCode, summary
+	t.Cleanup(func() { output, exitCode, summary = w, code, sum })
+	var buf bytes.Buffer
+	output = &buf
+	return &buf
+}
+
 func TestHandleFiles(t *testing.T) {
 	var files = []string{
 		"testdata/pkg1/pkg1.go",
 		"testdata/pkg1/pkg1_test.go",
 		"testdata/pkg1/ext_test.go",
 		"testdata/pkg1/generated.go",
+		"testdata/pkg1/suppressed.go",
 		"testdata/pkg2/pkg2.go",
 	}
 
-	// TODO(nishanth): This is gross. Restructure main to fix.
-	// Override globals.
-	var buf bytes.Buffer
-	output = &buf
+	buf := captureOutput(t)
 
 	// Run the test.
-	handleFiles(files)
+	handleReport(checkFiles(files))
 
 	const want = `testdata/pkg1/pkg1.go:10:6: VarArgsUnused has unused param s
 testdata/pkg1/pkg1.go:12:6: RegularArgsUnused has unused param y
 testdata/pkg1/pkg1.go:14:6: NakedReturnUnused has unused param x
 testdata/pkg1/pkg1.go:19:5: func has unused param x
 testdata/pkg1/pkg1.go:21:6: func has unused param y
-testdata/pkg1/pkg1.go:25:6: ScopeUnused has unused param n
+testdata/pkg1/pkg1.go:25:6: ScopeUnused param n is shadowed at 27:7 and never used
 testdata/pkg1/pkg1_test.go:3:6: bar has unused param x
+testdata/pkg1/suppressed.go:8:6: SuppressedByName has unused param x
 testdata/pkg1/ext_test.go:3:6: bar has unused param x
 testdata/pkg2/pkg2.go:3:6: qux has unused param x
 `
@@ -36,3 +57,807 @@ testdata/pkg2/pkg2.go:3:6: qux has unused param x
 		t.Errorf("want: %s\ngot:  %s", want, buf.String())
 	}
 }
+
+func TestRunTasksOrder(t *testing.T) {
+	var tasks []task
+	for i := 0; i < 20; i++ {
+		name := fmt.Sprintf("pkg%d", i)
+		tasks = append(tasks, func() *report {
+			return &report{Partial: []string{name}}
+		})
+	}
+
+	var got []string
+	runTasks(tasks, 4, func(r *report) {
+		got = append(got, r.Partial...)
+	})
+
+	if len(got) != len(tasks) {
+		t.Fatalf("want %d reports, got %d", len(tasks), len(got))
+	}
+	for i, name := range got {
+		if want := fmt.Sprintf("pkg%d", i); name != want {
+			t.Errorf("report %d: want %s, got %s", i, want, name)
+		}
+	}
+}
+
+func TestCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "unusedargs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(c resultCache) { cache = c }(cache)
+	cache = resultCache{dir: dir}
+
+	want := checkDir(&buildContext, "testdata/pkg2")
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("want 1 cache entry, got %d", len(entries))
+	}
+
+	got := checkDir(&buildContext, "testdata/pkg2")
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("cached report differs:\nwant: %+v\ngot:  %+v", want, got)
+	}
+
+	if err := cache.clean(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("cache directory not removed: %v", err)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "unusedargs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "a.go")
+	if err := ioutil.WriteFile(name, []byte("package a\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	before := snapshot([]string{dir})
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "notes.txt"), []byte("ignored"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if after := snapshot([]string{dir}); after != before {
+		t.Errorf("snapshot changed after writing a non-Go file")
+	}
+
+	if err := ioutil.WriteFile(name, []byte("package a\n\nfunc f(x int) {}\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if after := snapshot([]string{dir}); after == before {
+		t.Errorf("snapshot did not change after modifying a .go file")
+	}
+}
+
+func TestRerunStale(t *testing.T) {
+	dir, err := ioutil.TempDir("", "unusedargs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(f string, j int) { format, jobs = f, j }(format, jobs)
+	format, jobs = "json", 1
+
+	name := filepath.Join(dir, "a.go")
+	tasks := []task{func() *report { return checkDir(&buildContext, dir) }}
+	paths := [][]string{{dir}}
+	states := make([]watchState, 1)
+	for i, src := range []string{
+		"package a\n\nfunc f(x int) {}\n",
+		"package a\n\nfunc f(x int) {}\n\nfunc g(y int) {}\n",
+	} {
+		if err := ioutil.WriteFile(name, []byte(src), 0666); err != nil {
+			t.Fatal(err)
+		}
+		buf := captureOutput(t)
+		rerunStale(tasks, paths, states)
+		if got, want := strings.Count(buf.String(), `"position"`), i+1; got != want {
+			t.Errorf("run %d: printed %d findings, want %d:\n%s", i, got, want, buf.String())
+		}
+		if len(summary.Findings) > 0 || len(summary.Stats) > 0 {
+			t.Errorf("run %d: summary not reset", i)
+		}
+	}
+}
+
+func TestRerunStaleDeps(t *testing.T) {
+	gopath, err := ioutil.TempDir("", "unusedargs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(gopath)
+
+	defer resetImporters() // they import from the temporary GOPATH
+	defer func(j int, p string) { jobs, buildContext.GOPATH = j, p }(jobs, buildContext.GOPATH)
+	jobs, buildContext.GOPATH = 1, gopath
+	captureOutput(t)
+
+	write := func(name, src string) {
+		name = filepath.Join(gopath, "src", name)
+		if err := os.MkdirAll(filepath.Dir(name), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(name, []byte(src), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("p/dep/dep.go", "package dep\n\nfunc G(x int) int { return x }\n")
+	write("p/a/a.go", "package a\n\nimport \"p/dep\"\n\nfunc F(x int) int { return dep.G(x) }\n")
+
+	dir := filepath.Join(gopath, "src", "p", "a")
+	runs := 0
+	tasks := []task{func() *report {
+		runs++
+		return checkDir(&buildContext, dir)
+	}}
+	paths := [][]string{{dir}}
+	states := make([]watchState, 1)
+
+	rerunStale(tasks, paths, states)
+	rerunStale(tasks, paths, states)
+	if runs != 1 {
+		t.Fatalf("ran %d times without changes, want 1", runs)
+	}
+	write("p/dep/dep.go", "package dep\n\nfunc G(x, y int) int { return x }\n")
+	rerunStale(tasks, paths, states)
+	if runs != 2 {
+		t.Errorf("ran %d times after a dependency changed, want 2", runs)
+	}
+}
+
+func TestConfigs(t *testing.T) {
+	defer func(c configList) { configs = c }(configs)
+	configs = nil
+	for _, s := range []string{"linux/amd64", "windows/amd64", "linux/amd64,debug"} {
+		if err := configs.Set(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rep := forConfigs(func(ctx *build.Context) *report { return checkDir(ctx, "testdata/matrix") })()
+	if rep.Err != nil {
+		t.Fatal(rep.Err)
+	}
+
+	var got []string
+	for _, f := range rep.Findings {
+		got = append(got, f.String())
+	}
+	want := []string{
+		"testdata/matrix/debug.go:6:6: trace has unused param z [linux/amd64,debug]",
+		"testdata/matrix/matrix.go:3:6: Common has unused param x [linux/amd64 windows/amd64 linux/amd64,debug]",
+		"testdata/matrix/matrix_linux.go:3:6: platform has unused param y [linux/amd64 linux/amd64,debug]",
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+
+	// Each function is counted once, whatever the configurations that
+	// include it, and only reported findings are counted.
+	defer func(s string) { kindFilter = s }(kindFilter)
+	kindFilter = usages.FuncReceiver
+	captureOutput(t)
+	summary.Stats = nil
+	handleReport(rep)
+	wantStats := []pkgStats{{Package: "testdata/matrix (matrix)", Functions: 4, Params: 4}}
+	if !reflect.DeepEqual(wantStats, summary.Stats) {
+		t.Errorf("want stats %+v, got %+v", wantStats, summary.Stats)
+	}
+}
+
+func TestCgo(t *testing.T) {
+	ctx := buildContext
+	ctx.CgoEnabled = true
+
+	rep := checkDir(&ctx, "testdata/cgo")
+	if rep.Err != nil {
+		t.Fatal(rep.Err)
+	}
+	if len(rep.Partial) != 0 {
+		t.Errorf("want cgo package fully type checked, got partial %v", rep.Partial)
+	}
+
+	var got []string
+	for _, f := range rep.Findings {
+		got = append(got, f.String())
+	}
+	want := []string{"testdata/cgo/cgo.go:13:6: Unused has unused param y"}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+
+	wantStats := []pkgStats{{
+		Package:      "testdata/cgo (cgo)",
+		Functions:    3,
+		Params:       3,
+		UnusedParams: 1,
+		Suppressed:   1,
+	}}
+	if stats := countStats(rep, rep.Findings); !reflect.DeepEqual(wantStats, stats) {
+		t.Errorf("want stats %+v, got %+v", wantStats, stats)
+	}
+}
+
+func TestTestPackages(t *testing.T) {
+	defer func(b bool) { tests = b }(tests)
+
+	for _, tt := range []struct {
+		tests bool
+		want  []string
+	}{
+		{true, []string{
+			"testdata/xtest/xtest_test.go:3:6: helper has unused param v",
+			"testdata/xtest/ext_test.go:5:6: use has unused param u",
+		}},
+		{false, nil},
+	} {
+		tests = tt.tests
+		rep := checkDir(&buildContext, "testdata/xtest")
+		if rep.Err != nil {
+			t.Fatal(rep.Err)
+		}
+		if len(rep.Partial) != 0 {
+			t.Errorf("tests=%t: want packages fully type checked, got partial %v", tt.tests, rep.Partial)
+		}
+		var got []string
+		for _, f := range rep.Findings {
+			got = append(got, f.String())
+		}
+		if !reflect.DeepEqual(tt.want, got) {
+			t.Errorf("tests=%t: want:\n%s\ngot:\n%s", tt.tests, strings.Join(tt.want, "\n"), strings.Join(got, "\n"))
+		}
+	}
+}
+
+func TestImporters(t *testing.T) {
+	defer func(s string) { importerName = s }(importerName)
+
+	// generated.go imports context, which can only be type checked if
+	// the importer can load the standard library.
+	files := []string{"testdata/pkg1/pkg1.go", "testdata/pkg1/generated.go"}
+	for _, name := range []string{usages.ImporterAuto, usages.ImporterGoList, usages.ImporterSource} {
+		importerName = name
+		rep := checkFiles(files)
+		if rep.Err != nil {
+			t.Fatalf("%s: %v", name, rep.Err)
+		}
+		if len(rep.Partial) != 0 {
+			t.Errorf("%s: want package fully type checked, got partial %v", name, rep.Partial)
+		}
+	}
+}
+
+func TestHTML(t *testing.T) {
+	rep := checkFiles([]string{"testdata/pkg1/pkg1.go"})
+	if rep.Err != nil {
+		t.Fatal(rep.Err)
+	}
+
+	var buf bytes.Buffer
+	if err := writeHTML(&buf, rep.Findings, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`<h3>testdata/pkg1/pkg1.go</h3>`,
+		`<p>testdata/pkg1/pkg1.go:12:6: RegularArgsUnused has unused param <span class="unused">y</span></p>`,
+		`<p>testdata/pkg1/pkg1.go:25:6: ScopeUnused param <span class="unused">n</span> is shadowed at 27:7 and never used</p>`,
+		`<span class="ln">12</span>func RegularArgsUnused(x, <span class="unused">y</span> int)          { _ = <span class="use">x</span> }`,
+		`param <span class="use">x</span>: 1 use testdata/pkg1/pkg1.go:12:49`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("report does not contain %q", want)
+		}
+	}
+}
+
+func TestFilters(t *testing.T) {
+	defer func(k, v string) { kindFilter, visibilityFilter = k, v }(kindFilter, visibilityFilter)
+
+	rep := checkFiles([]string{"testdata/pkg2/visibility.go"})
+	if rep.Err != nil {
+		t.Fatal(rep.Err)
+	}
+
+	for _, tt := range []struct {
+		kind, visibility string
+		want             []string
+	}{
+		{"", "", []string{"e Method", "x Method", "e method", "x method", "u Method", "x Method", "x Func", "y func"}},
+		{"receiver", "", []string{"e Method", "e method", "u Method"}},
+		{"", "exported", []string{"e Method", "x Method", "x Func"}},
+		{"param", "unexported", []string{"x method", "x Method", "y func"}},
+	} {
+		kindFilter, visibilityFilter = tt.kind, tt.visibility
+		var got []string
+		for _, f := range rep.Findings {
+			if matchesFilters(f) {
+				got = append(got, f.Name+" "+f.FuncName)
+			}
+		}
+		if !reflect.DeepEqual(tt.want, got) {
+			t.Errorf("-kind=%q -visibility=%q: want %v, got %v", tt.kind, tt.visibility, tt.want, got)
+		}
+	}
+}
+
+func TestUses(t *testing.T) {
+	for _, tt := range []struct {
+		query, want string
+	}{
+		{"./testdata/pkg1.RegularArgsUnused", `testdata/pkg1/pkg1.go:12:6: RegularArgsUnused
+	param x: 1 use
+		testdata/pkg1/pkg1.go:12:49
+	param y: 0 uses
+`},
+		{"./testdata/pkg2.Exported.Method", `testdata/pkg2/visibility.go:7:19: Exported.Method
+	receiver e: 0 uses
+	param x: 0 uses
+`},
+		{"./testdata/dotted.v2.Marshal", `testdata/dotted.v2/dotted.go:3:6: Marshal
+	param v: 0 uses
+	param indent: 0 uses
+`},
+		{"./testdata/dotted.v2.Decoder.Decode", `testdata/dotted.v2/dotted.go:9:19: Decoder.Decode
+	receiver d: 0 uses
+	param v: 0 uses
+`},
+		{"testdata/pkg1/pkg1.go:20", `testdata/pkg1/pkg1.go:19:5: func
+	param x: 0 uses
+`},
+		{"testdata/pkg1/pkg1.go:45", `testdata/pkg1/pkg1.go:36:6: ScopeUsed
+	param n: 1 use
+		testdata/pkg1/pkg1.go:45:10
+`},
+	} {
+		var buf bytes.Buffer
+		if err := printUses(&buf, tt.query); err != nil {
+			t.Errorf("%s: %v", tt.query, err)
+			continue
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("%s: want\n%s\ngot\n%s", tt.query, tt.want, got)
+		}
+	}
+
+	for _, query := range []string{"./testdata/pkg1.NoSuchFunc", "./testdata/pkg1.UnnamedParam", "testdata/pkg1/pkg1.go:x", "Func", "./testdata/dotted.v2"} {
+		if err := printUses(ioutil.Discard, query); err == nil {
+			t.Errorf("%s: want error", query)
+		}
+	}
+}
+
+func TestInterfaces(t *testing.T) {
+	defer func(b bool) { checkInterfaces = b }(checkInterfaces)
+	checkInterfaces = true
+
+	buf := captureOutput(t)
+	handleReport(checkDir(&buildContext, "testdata/iface"))
+	buf.Reset() // only interested in the interface findings
+	reportAbstract()
+
+	const want = `testdata/iface/iface.go:4:2: Getter.Get has param opts unused by all 2 implementations
+testdata/iface/iface.go:10:2: Store.Delete has param #2 unused by all 2 implementations
+`
+	if got := buf.String(); got != want {
+		t.Errorf("want: %s\ngot:  %s", want, got)
+	}
+	if len(abstract.Funcs) != 0 || len(abstract.Impls) != 0 {
+		t.Error("records not cleared")
+	}
+}
+
+func TestFuncTypes(t *testing.T) {
+	defer func(b bool) { checkFuncTypes = b }(checkFuncTypes)
+	checkFuncTypes = true
+
+	buf := captureOutput(t)
+	handleReport(checkDir(&buildContext, "testdata/functype"))
+	buf.Reset() // only interested in the func type findings
+	reportAbstract()
+
+	const want = `testdata/functype/functype.go:5:6: Middleware has param cfg unused by all 3 implementations
+testdata/functype/functype.go:7:6: Hook has param name unused by its only implementation
+`
+	if got := buf.String(); got != want {
+		t.Errorf("want: %s\ngot:  %s", want, got)
+	}
+}
+
+func TestContext(t *testing.T) {
+	buf := captureOutput(t)
+	handleReport(checkDir(&buildContext, "testdata/ctx"))
+
+	const want = `testdata/ctx/ctx.go:8:6: fetch has unused context ctx
+testdata/ctx/ctx.go:10:6: Handle has unused context ctx; forward it instead of context.Background() at 11:15
+testdata/ctx/ctx.go:14:6: Poll has unused context ctx; forward it instead of context.TODO() at 15:24
+`
+	if got := buf.String(); got != want {
+		t.Errorf("want: %s\ngot:  %s", want, got)
+	}
+
+	// A used context is reported as a param.
+	defer func(b bool) { checkLogging = b }(checkLogging)
+	checkLogging = true
+	buf.Reset()
+	handleReport(checkDir(&buildContext, "testdata/ctx"))
+	const logged = "testdata/ctx/ctx.go:20:6: Trace has param ctx only used in logging\n"
+	if got := buf.String(); got != want+logged {
+		t.Errorf("with -logging: want: %s\ngot:  %s", want+logged, got)
+	}
+}
+
+func TestLogging(t *testing.T) {
+	defer func(b bool, s string) { checkLogging, extraLogFuncs = b, s }(checkLogging, extraLogFuncs)
+	checkLogging = true
+
+	for _, tt := range []struct {
+		funcs string
+		want  []string
+	}{
+		{"", []string{
+			"testdata/logging/logging.go:13:6: Save has param id only used in logging",
+			"testdata/logging/logging.go:29:6: Notify has param user only used in logging",
+		}},
+		{"(*github.com/nishanths/unusedargs/testdata/logging/logger.Logger).Infow", []string{
+			"testdata/logging/logging.go:13:6: Save has param id only used in logging",
+			"testdata/logging/logging.go:18:6: Load has param key only used in logging",
+			"testdata/logging/logging.go:29:6: Notify has param user only used in logging",
+		}},
+	} {
+		extraLogFuncs = tt.funcs
+		rep := checkDir(&buildContext, "testdata/logging")
+		if rep.Err != nil {
+			t.Fatal(rep.Err)
+		}
+		var got []string
+		for _, f := range rep.Findings {
+			got = append(got, f.String())
+		}
+		if !reflect.DeepEqual(tt.want, got) {
+			t.Errorf("-logfuncs=%q: want:\n%s\ngot:\n%s", tt.funcs, strings.Join(tt.want, "\n"), strings.Join(got, "\n"))
+		}
+	}
+}
+
+func TestDeadCode(t *testing.T) {
+	defer func(b bool) { checkDeadCode = b }(checkDeadCode)
+	checkDeadCode = true
+
+	buf := captureOutput(t)
+	handleReport(checkDir(&buildContext, "testdata/dead"))
+
+	const want = `testdata/dead/dead.go:5:6: Flagged has param x only used in dead code
+testdata/dead/dead.go:12:6: Never has param s only used in dead code
+testdata/dead/dead.go:18:6: Discarded has param a only used in dead code
+`
+	if got := buf.String(); got != want {
+		t.Errorf("want: %s\ngot:  %s", want, got)
+	}
+}
+
+func TestDeadCodeSkipped(t *testing.T) {
+	defer func(b bool) { checkDeadCode = b }(checkDeadCode)
+	checkDeadCode = true
+
+	rep := checkDir(&buildContext, "testdata/deadgeneric")
+	if rep.Err != nil {
+		t.Fatal(rep.Err)
+	}
+	if want := []string{"deadgeneric"}; !reflect.DeepEqual(rep.NoDead, want) {
+		t.Errorf("NoDead: want %v, got %v", want, rep.NoDead)
+	}
+	for _, f := range rep.Findings {
+		if f.Dead {
+			t.Errorf("unexpected dead code finding: %s", f.message())
+		}
+	}
+}
+
+func TestNarrow(t *testing.T) {
+	defer func(b bool) { checkNarrow = b }(checkNarrow)
+	checkNarrow = true
+
+	buf := captureOutput(t)
+	handleReport(checkDir(&buildContext, "testdata/narrow"))
+
+	const want = `testdata/narrow/narrow.go:13:6: Copy has param f that only uses Read; consider io.Reader
+testdata/narrow/narrow.go:23:6: Drain has param f that only uses Close, Read; consider io.ReadCloser
+testdata/narrow/narrow.go:28:6: Label has param f that only uses Name; consider Named
+`
+	if got := buf.String(); got != want {
+		t.Errorf("want: %s\ngot:  %s", want, got)
+	}
+}
+
+func TestFields(t *testing.T) {
+	defer func(f float64) { fieldsFraction = f }(fieldsFraction)
+	fieldsFraction = 0.25
+
+	buf := captureOutput(t)
+	handleReport(checkDir(&buildContext, "testdata/fields"))
+
+	const want = `testdata/fields/fields.go:18:6: Dial has param cfg that only uses 2 of 8 fields: Addr, Port
+testdata/fields/fields.go:22:6: ID has param cfg that only uses 1 of 8 fields: Base
+`
+	if got := buf.String(); got != want {
+		t.Errorf("want: %s\ngot:  %s", want, got)
+	}
+}
+
+func TestChanDir(t *testing.T) {
+	defer func(b bool) { checkChanDir = b }(checkChanDir)
+	checkChanDir = true
+
+	buf := captureOutput(t)
+	handleReport(checkDir(&buildContext, "testdata/chandir"))
+
+	const want = `testdata/chandir/chandir.go:3:6: Produce has param out that is only sent on; consider chan<- int
+testdata/chandir/chandir.go:10:6: Consume has param in that is only received from; consider <-chan int
+testdata/chandir/chandir.go:18:6: First has param in that is only received from; consider <-chan string
+testdata/chandir/chandir.go:18:6: First has param done that is only received from; consider <-chan string
+testdata/chandir/chandir.go:31:6: Relay has param ch that is only sent on; consider chan<- int
+`
+	if got := buf.String(); got != want {
+		t.Errorf("want: %s\ngot:  %s", want, got)
+	}
+}
+
+func TestChanDirFix(t *testing.T) {
+	defer func(check, fix bool) { checkChanDir, fixChanDir = check, fix }(checkChanDir, fixChanDir)
+	checkChanDir, fixChanDir = true, true
+
+	dir, err := ioutil.TempDir("", "unusedargs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src, err := ioutil.ReadFile("testdata/chandir/chandir.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(dir, "chandir.go")
+	if err := ioutil.WriteFile(name, src, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(m map[string]map[int]bool) { chanFixes = m }(chanFixes)
+	chanFixes = make(map[string]map[int]bool)
+	captureOutput(t)
+	handleReport(checkDir(&buildContext, dir))
+	if err := applyChanFixes(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// First's params share a type, so it isn't rewritten.
+	want := strings.NewReplacer(
+		"Produce(out chan int", "Produce(out chan<- int",
+		"Consume(in chan int", "Consume(in <-chan int",
+		"Relay(ch chan int", "Relay(ch chan<- int",
+	).Replace(string(src))
+	if string(got) != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestPointers(t *testing.T) {
+	defer func(b bool) { checkPointers = b }(checkPointers)
+	checkPointers = true
+
+	buf := captureOutput(t)
+	handleReport(checkDir(&buildContext, "testdata/pointers"))
+
+	const want = `testdata/pointers/pointers.go:9:6: Sum has param p that could be passed by value
+testdata/pointers/pointers.go:13:6: Copy has param p that could be passed by value
+testdata/pointers/pointers.go:38:16: Reset has value receiver p assigned to at 39:2, which is lost when it returns
+testdata/pointers/pointers.go:52:16: Area has receiver s that could be passed by value
+testdata/pointers/pointers.go:94:6: AddInts has param p that could be passed by value
+`
+	if got := buf.String(); got != want {
+		t.Errorf("want: %s\ngot:  %s", want, got)
+	}
+}
+
+func TestAbstractPackages(t *testing.T) {
+	defer func(i, f bool) { checkInterfaces, checkFuncTypes = i, f }(checkInterfaces, checkFuncTypes)
+	checkInterfaces, checkFuncTypes = true, true
+
+	for _, tt := range []struct {
+		dirs []string
+		want string
+	}{
+		// Same names in unrelated packages.
+		{[]string{"c2", "c3"}, `testdata/ifacepkgs/c2/c2.go:4:2: Store.Get has param opts unused by its only implementation
+testdata/ifacepkgs/c2/c2.go:7:6: Hook has param name unused by its only implementation
+`},
+		// Declared in one package, implemented in another.
+		{[]string{"a", "b"}, `testdata/ifacepkgs/a/a.go:4:2: Store.Get has param opts unused by its only implementation
+testdata/ifacepkgs/a/a.go:7:6: Hook has param name unused by its only implementation
+`},
+	} {
+		buf := captureOutput(t)
+		for _, dir := range tt.dirs {
+			handleReport(checkDir(&buildContext, filepath.Join("testdata/ifacepkgs", dir)))
+		}
+		buf.Reset() // only interested in the interface and func type findings
+		reportAbstract()
+		if got := buf.String(); got != tt.want {
+			t.Errorf("%v: want: %s\ngot:  %s", tt.dirs, tt.want, got)
+		}
+	}
+}
+
+func TestMergeReports(t *testing.T) {
+	cs := []config{{name: "a"}, {name: "b"}}
+	pos := func(file string, off int) token.Position {
+		return token.Position{Filename: file, Offset: off, Line: 1, Column: off + 1}
+	}
+	unused := finding{Position: pos("x.go", 5), FuncPosition: pos("x.go", 0), FuncName: "F", Kind: usages.FuncParam, Name: "p"}
+	logged := unused
+	logged.LogOnly = true
+	other := finding{Position: pos("w.go", 5), FuncPosition: pos("w.go", 0), FuncName: "G", Kind: usages.FuncParam, Name: "q"}
+
+	merged := mergeReports(cs, []*report{
+		{Files: []string{"x.go", "w.go"}, Findings: []finding{unused, other}, Skipped: []string{"s.go"}},
+		{Files: []string{"x.go", "w.go"}, Findings: []finding{logged, other}, Skipped: []string{"s.go"}, Partial: []string{"p"}},
+	})
+
+	// The findings at x.go differ between the configurations.
+	var got []string
+	for _, f := range merged.Findings {
+		got = append(got, f.String())
+	}
+	want := []string{"w.go:1:1: G has unused param q [a b]"}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("findings: want %q, got %q", want, got)
+	}
+	if want := []string{"s.go"}; !reflect.DeepEqual(want, merged.Skipped) {
+		t.Errorf("skipped: want %q, got %q", want, merged.Skipped)
+	}
+	if want := []string{"p (b)"}; !reflect.DeepEqual(want, merged.Partial) {
+		t.Errorf("partial: want %q, got %q", want, merged.Partial)
+	}
+}
+
+func TestImporterFor(t *testing.T) {
+	defer resetImporters()
+	resetImporters()
+
+	linux, windows := buildContext, buildContext
+	linux.GOOS, windows.GOOS = "linux", "windows"
+	imp := importerFor(&linux)
+	if got := importerFor(&linux); got != imp {
+		t.Errorf("importerFor returned a new importer for the same configuration")
+	}
+	if got := importerFor(&windows); got == imp {
+		t.Errorf("importerFor shared an importer between configurations")
+	}
+	resetImporters()
+	if got := importerFor(&linux); got == imp {
+		t.Errorf("importerFor returned an importer from a previous run")
+	}
+}
+
+// dirImporter records the directories that imports are made from.
+type dirImporter struct {
+	types.ImporterFrom
+	dirs map[string]bool
+}
+
+func (d *dirImporter) ImportFrom(path, dir string, mode types.ImportMode) (*types.Package, error) {
+	d.dirs[dir] = true
+	return d.ImporterFrom.ImportFrom(path, dir, mode)
+}
+
+func TestImportFromDir(t *testing.T) {
+	contents, _, err := readFiles([]string{"testdata/ctx/ctx.go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &dirImporter{dirs: make(map[string]bool)}
+	imp := newSharedImporter(func(fset *token.FileSet) types.Importer {
+		d.ImporterFrom = usages.NewImporter(usages.ImporterSource, &buildContext, fset)
+		return d
+	})
+	if rep := analyse("", buildContext.GOARCH, imp, contents, nil); rep.Err != nil || len(rep.Partial) > 0 {
+		t.Fatalf("analyse: %v %v", rep.Err, rep.Partial)
+	}
+	if want := map[string]bool{"testdata/ctx": true}; !reflect.DeepEqual(d.dirs, want) {
+		t.Errorf("imported from %v, want %v", d.dirs, want)
+	}
+}
+
+func TestImporterConfig(t *testing.T) {
+	defer func(name string) { importerName = name }(importerName)
+	defer resetImporters()
+
+	for _, name := range []string{usages.ImporterSource, usages.ImporterGoList} {
+		importerName = name
+		for _, tt := range []struct {
+			goos    string
+			partial bool
+		}{
+			{"windows", false},
+			{"linux", true}, // dep.Win is only declared on windows
+		} {
+			resetImporters()
+			ctx := buildContext
+			ctx.GOOS, ctx.GOARCH, ctx.CgoEnabled = tt.goos, "amd64", false
+			rep := checkDir(&ctx, "testdata/tagdeps/w")
+			if rep.Err != nil {
+				t.Fatal(rep.Err)
+			}
+			if got := len(rep.Partial) > 0; got != tt.partial {
+				t.Errorf("-importer %s, GOOS %s: partial %t, want %t", name, tt.goos, got, tt.partial)
+			}
+		}
+	}
+}
+
+func TestCacheKeyDeps(t *testing.T) {
+	defer resetDepIDs()
+	gopath, err := ioutil.TempDir("", "unusedargs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(gopath)
+
+	write := func(name, src string) {
+		name = filepath.Join(gopath, "src", name)
+		if err := os.MkdirAll(filepath.Dir(name), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(name, []byte(src), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("p/go.mod", "module p\n")
+	write("p/dep/dep.go", "package dep\n\ntype I interface{ M() }\n")
+	write("p/a/a.go", "package a\n\nimport \"p/dep\"\n\nvar _ dep.I\n")
+
+	ctx := buildContext
+	ctx.GOPATH = gopath
+	key := func() string {
+		resetDepIDs()
+		pkg, err := ctx.ImportDir(filepath.Join(gopath, "src", "p", "a"), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents, _, err := readFiles(pkgFiles(pkg))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cacheKey(&ctx, pkg, contents)
+	}
+
+	for _, tt := range []struct {
+		name, src string
+	}{
+		{"p/dep/dep.go", "package dep\n\ntype I interface{ M(); N() }\n"},
+		{"p/go.mod", "module p\n\ngo 1.21\n"},
+		{"p/go.sum", "example.com/x v1.0.0 h1:abc=\n"},
+	} {
+		before := key()
+		write(tt.name, tt.src)
+		if key() == before {
+			t.Errorf("cache key unchanged after editing %s", tt.name)
+		}
+	}
+}
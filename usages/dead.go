@@ -0,0 +1,250 @@
+package usages
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// deadInputs builds SSA form for the type checked package, and returns
+// the receivers and params of its functions whose values never reach
+// an instruction with an effect: a call, a store, a return, a branch,
+// one that may panic, and so on. Blocks only reachable through a branch on a constant
+// condition, such as "if false" or "if debug" with a constant debug,
+// are treated as unreachable. It returns nil if the SSA form can't be
+// built.
+func deadInputs(fset *token.FileSet, pkg *types.Package, files []*ast.File, info *types.Info) (dead map[types.Object]bool) {
+	defer func() {
+		if recover() != nil {
+			dead = nil // e.g. generic code, which go/ssa doesn't support
+		}
+	}()
+
+	prog := ssa.NewProgram(fset, 0)
+	created := make(map[*types.Package]bool)
+	var createAll func(pkgs []*types.Package)
+	createAll = func(pkgs []*types.Package) {
+		for _, p := range pkgs {
+			if !created[p] {
+				created[p] = true
+				prog.CreatePackage(p, nil, nil, true)
+				createAll(p.Imports())
+			}
+		}
+	}
+	createAll(pkg.Imports())
+	ssapkg := prog.CreatePackage(pkg, files, info, false)
+	ssapkg.Build()
+
+	dead = make(map[types.Object]bool)
+	seen := make(map[*ssa.Function]bool)
+	var visit func(fn *ssa.Function)
+	visit = func(fn *ssa.Function) {
+		if fn == nil || seen[fn] || fn.Pkg != ssapkg || fn.Synthetic != "" {
+			return
+		}
+		seen[fn] = true
+		live := liveValues(fn)
+		for _, p := range fn.Params {
+			if obj := p.Object(); obj != nil && !live[p] {
+				dead[obj] = true
+			}
+		}
+		for _, anon := range fn.AnonFuncs {
+			visit(anon)
+		}
+	}
+	for _, mem := range ssapkg.Members {
+		switch mem := mem.(type) {
+		case *ssa.Function:
+			visit(mem)
+		case *ssa.Type:
+			for _, t := range []types.Type{mem.Type(), types.NewPointer(mem.Type())} {
+				mset := prog.MethodSets.MethodSet(t)
+				for i := 0; i < mset.Len(); i++ {
+					visit(prog.MethodValue(mset.At(i)))
+				}
+			}
+		}
+	}
+	return dead
+}
+
+// liveValues returns the values in the function that reach an
+// instruction with an effect in a reachable block.
+func liveValues(fn *ssa.Function) map[ssa.Value]bool {
+	type edge struct{ from, to *ssa.BasicBlock }
+	reachable := make(map[*ssa.BasicBlock]bool)
+	edges := make(map[edge]bool)
+	var walk func(b *ssa.BasicBlock)
+	walk = func(b *ssa.BasicBlock) {
+		if reachable[b] {
+			return
+		}
+		reachable[b] = true
+		succs := b.Succs
+		if len(b.Instrs) > 0 {
+			if i, ok := b.Instrs[len(b.Instrs)-1].(*ssa.If); ok {
+				if c, ok := i.Cond.(*ssa.Const); ok && c.Value != nil && c.Value.Kind() == constant.Bool {
+					if constant.BoolVal(c.Value) {
+						succs = succs[:1]
+					} else {
+						succs = succs[1:]
+					}
+				}
+			}
+		}
+		for _, s := range succs {
+			edges[edge{b, s}] = true
+			walk(s)
+		}
+	}
+	if len(fn.Blocks) > 0 {
+		walk(fn.Blocks[0])
+	}
+	if fn.Recover != nil {
+		walk(fn.Recover)
+	}
+
+	live := make(map[ssa.Value]bool)
+	var queue []ssa.Instruction
+	for _, b := range fn.Blocks {
+		if !reachable[b] {
+			continue
+		}
+		for _, instr := range b.Instrs {
+			if hasEffect(instr) {
+				queue = append(queue, instr)
+			}
+		}
+	}
+	var rands []*ssa.Value
+	for len(queue) > 0 {
+		instr := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		rands = rands[:0]
+		if phi, ok := instr.(*ssa.Phi); ok {
+			for i := range phi.Edges {
+				if edges[edge{phi.Block().Preds[i], phi.Block()}] {
+					rands = append(rands, &phi.Edges[i])
+				}
+			}
+		} else {
+			rands = instr.Operands(rands)
+		}
+		for _, rand := range rands {
+			v := *rand
+			if v == nil || live[v] {
+				continue
+			}
+			live[v] = true
+			if in, ok := v.(ssa.Instruction); ok && reachable[in.Block()] {
+				queue = append(queue, in)
+			}
+		}
+	}
+	return live
+}
+
+// hasEffect reports whether the instruction has an effect other than
+// computing a value, including panicking, such as on an index out of
+// range or a nil pointer.
+func hasEffect(instr ssa.Instruction) bool {
+	switch instr := instr.(type) {
+	case *ssa.Call, *ssa.Select:
+		return true
+	case *ssa.IndexAddr, *ssa.Index, *ssa.Slice, *ssa.FieldAddr, *ssa.MakeSlice:
+		return true // may panic
+	case *ssa.Lookup:
+		_, str := instr.X.Type().Underlying().(*types.Basic)
+		return str // string index may panic
+	case *ssa.UnOp:
+		return instr.Op == token.ARROW || instr.Op == token.MUL // receive, or load that may panic
+	case *ssa.BinOp:
+		return (instr.Op == token.QUO || instr.Op == token.REM) && mayDivideByZero(instr)
+	case *ssa.TypeAssert:
+		return !instr.CommaOk // may panic
+	case ssa.Value:
+		return false
+	}
+	return true // Store, If, Return, Panic, Send, MapUpdate, Go, Defer, ...
+}
+
+// mayDivideByZero reports whether the division or remainder is of
+// integers by a value that isn't a non-zero constant.
+func mayDivideByZero(instr *ssa.BinOp) bool {
+	b, ok := instr.Type().Underlying().(*types.Basic)
+	if !ok || b.Info()&types.IsInteger == 0 {
+		return false
+	}
+	c, ok := instr.Y.(*ssa.Const)
+	return !ok || c.Value == nil || constant.Sign(c.Value) == 0
+}
+
+// blankAssigned reports whether an expression computed from one of the
+// uses, directly or through local variables, is assigned to the blank
+// identifier in the function, which is taken to mean that the use is
+// deliberate, as in "_ = b[1]" to check bounds.
+func blankAssigned(fn ast.Node, uses []*ast.Ident, info *types.Info) bool {
+	isUse := make(map[*ast.Ident]bool)
+	for _, u := range uses {
+		isUse[u] = true
+	}
+	derived := make(map[types.Object]bool) // variables computed from the uses
+	computed := func(e ast.Expr) bool {
+		found := false
+		ast.Inspect(e, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok && (isUse[id] || derived[info.Uses[id]]) {
+				found = true
+			}
+			return !found
+		})
+		return found
+	}
+
+	// eachAssign calls f for each variable or blank identifier assigned
+	// to in the function, with the expression assigned to it.
+	eachAssign := func(f func(lhs *ast.Ident, rhs ast.Expr)) {
+		ast.Inspect(fn, func(n ast.Node) bool {
+			var lhs, rhs []ast.Expr
+			switch n := n.(type) {
+			case *ast.AssignStmt:
+				lhs, rhs = n.Lhs, n.Rhs
+			case *ast.ValueSpec:
+				for _, name := range n.Names {
+					lhs = append(lhs, name)
+				}
+				rhs = n.Values
+			}
+			for i, l := range lhs {
+				id, ok := l.(*ast.Ident)
+				switch {
+				case !ok:
+				case len(rhs) == len(lhs):
+					f(id, rhs[i])
+				case len(rhs) == 1:
+					f(id, rhs[0]) // multi-value expression
+				}
+			}
+			return true
+		})
+	}
+
+	for changed := true; changed; {
+		changed = false
+		eachAssign(func(lhs *ast.Ident, rhs ast.Expr) {
+			if obj := info.ObjectOf(lhs); obj != nil && !derived[obj] && computed(rhs) {
+				derived[obj] = true
+				changed = true
+			}
+		})
+	}
+	found := false
+	eachAssign(func(lhs *ast.Ident, rhs ast.Expr) {
+		found = found || isBlankIdent(lhs) && computed(rhs)
+	})
+	return found
+}
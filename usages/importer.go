@@ -0,0 +1,351 @@
+package usages
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/go/gccgoexportdata"
+	"golang.org/x/tools/go/gcexportdata"
+)
+
+// Importer names accepted by NewImporter.
+const (
+	ImporterAuto   = "auto"   // try gc, then golist, then source
+	ImporterGc     = "gc"     // gc export data installed in $GOPATH/pkg
+	ImporterGoList = "golist" // gc export data built by 'go list -export'
+	ImporterSource = "source" // type check dependencies from source
+	ImporterGccgo  = "gccgo"  // gccgo export data
+)
+
+// NewImporter returns the importer with the name, which must be one of
+// the Importer constants, or nil if there is no such importer. The
+// importer finds dependencies as built for the configuration ctx, or
+// for build.Default if ctx is nil. It records positions in fset, and is
+// not safe for concurrent use.
+//
+// The auto importer tries each of the gc, golist, and source importers
+// in turn, until one of them succeeds. Since each importer loads
+// dependencies independently, types from packages loaded by different
+// importers may not be identical; auto is intended for when one of the
+// importers works for most imports.
+func NewImporter(name string, ctx *build.Context, fset *token.FileSet) types.ImporterFrom {
+	if ctx == nil {
+		ctx = &build.Default
+	}
+	switch name {
+	case ImporterAuto:
+		return fallbackImporter{
+			NewImporter(ImporterGc, ctx, fset),
+			NewImporter(ImporterGoList, ctx, fset),
+			NewImporter(ImporterSource, ctx, fset),
+		}
+	case ImporterGc:
+		return &gcImporter{ctx: ctx, fset: fset, imports: make(map[string]*types.Package)}
+	case ImporterGoList:
+		return &goListImporter{ctx: ctx, fset: fset}
+	case ImporterSource:
+		return &sourceImporter{
+			ctx:      ctx,
+			fset:     fset,
+			sizes:    types.SizesFor(ctx.Compiler, ctx.GOARCH),
+			packages: make(map[string]*types.Package),
+		}
+	case ImporterGccgo:
+		return &gccgoImporter{ctx: ctx, fset: fset, imports: make(map[string]*types.Package)}
+	}
+	return nil
+}
+
+// findPackage finds the package with the import path, as imported from
+// dir in the configuration ctx. In module mode, go/build resolves the
+// import from the module containing ctx.Dir rather than the one
+// containing dir, so the import is made with ctx.Dir set to dir.
+func findPackage(ctx *build.Context, path, dir string, mode build.ImportMode) (*build.Package, error) {
+	c := *ctx
+	if dir != "" {
+		if abs, err := filepath.Abs(dir); err == nil {
+			c.Dir, dir = abs, abs
+		}
+	}
+	return c.Import(path, dir, mode)
+}
+
+// fallbackImporter imports a package using the first of the importers
+// that succeeds.
+type fallbackImporter []types.ImporterFrom
+
+func (f fallbackImporter) Import(path string) (*types.Package, error) {
+	return f.ImportFrom(path, "", 0)
+}
+
+func (f fallbackImporter) ImportFrom(path, dir string, mode types.ImportMode) (*types.Package, error) {
+	var errs []string
+	for _, imp := range f {
+		pkg, err := imp.ImportFrom(path, dir, mode)
+		if err == nil {
+			return pkg, nil
+		}
+		errs = append(errs, err.Error())
+	}
+	return nil, fmt.Errorf("can't import %s: %s", path, strings.Join(errs, "; "))
+}
+
+// gcImporter reads gc export data installed in $GOPATH/pkg.
+type gcImporter struct {
+	ctx     *build.Context
+	fset    *token.FileSet
+	imports map[string]*types.Package
+}
+
+func (g *gcImporter) Import(path string) (*types.Package, error) {
+	return g.ImportFrom(path, "", 0)
+}
+
+func (g *gcImporter) ImportFrom(path, dir string, _ types.ImportMode) (*types.Package, error) {
+	if path == "unsafe" {
+		return types.Unsafe, nil
+	}
+	bp, _ := findPackage(g.ctx, path, dir, build.FindOnly|build.AllowBinary)
+	if bp == nil || bp.PkgObj == "" {
+		return nil, fmt.Errorf("can't find import: %s", path)
+	}
+	if pkg, ok := g.imports[bp.ImportPath]; ok && pkg.Complete() {
+		return pkg, nil
+	}
+	f, err := os.Open(bp.PkgObj)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r, err := gcexportdata.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("reading export data: %s: %v", bp.PkgObj, err)
+	}
+	pkg, err := gcexportdata.Read(r, g.fset, g.imports, bp.ImportPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading export data: %s: %v", bp.PkgObj, err)
+	}
+	return pkg, nil
+}
+
+// goListImporter reads gc export data located, and built if necessary,
+// by 'go list -export'.
+type goListImporter struct {
+	ctx  *build.Context
+	fset *token.FileSet
+	imp  types.ImporterFrom // reads the export data files
+
+	mu      sync.Mutex
+	exports map[string]string // export data file by import path
+	errs    map[string]error  // failures to list, by import path
+}
+
+func (g *goListImporter) Import(path string) (*types.Package, error) {
+	return g.ImportFrom(path, "", 0)
+}
+
+func (g *goListImporter) ImportFrom(path, dir string, mode types.ImportMode) (*types.Package, error) {
+	if path == "unsafe" {
+		return types.Unsafe, nil
+	}
+	if g.imp == nil {
+		lookup := func(path string) (io.ReadCloser, error) {
+			g.mu.Lock()
+			file := g.exports[path]
+			g.mu.Unlock()
+			if file == "" {
+				return nil, fmt.Errorf("no export data for %s", path)
+			}
+			return os.Open(file)
+		}
+		g.imp = importer.ForCompiler(g.fset, "gc", lookup).(types.ImporterFrom)
+	}
+	if err := g.list(path, dir); err != nil {
+		return nil, err
+	}
+	return g.imp.ImportFrom(path, dir, mode)
+}
+
+// list records the export data files of the package with the import
+// path and its dependencies, unless already known.
+func (g *goListImporter) list(path, dir string) error {
+	g.mu.Lock()
+	_, ok := g.exports[path]
+	err := g.errs[path]
+	g.mu.Unlock()
+	if ok || err != nil {
+		return err
+	}
+
+	cmd := exec.Command("go", "list", "-export", "-deps", "-tags="+strings.Join(g.ctx.BuildTags, ","),
+		"-f", "{{.ImportPath}}\t{{.Export}}", path)
+	cmd.Dir = dir
+	cgo := "0"
+	if g.ctx.CgoEnabled {
+		cgo = "1"
+	}
+	cmd.Env = append(os.Environ(),
+		"GOOS="+g.ctx.GOOS,
+		"GOARCH="+g.ctx.GOARCH,
+		"GOROOT="+g.ctx.GOROOT,
+		"GOPATH="+g.ctx.GOPATH,
+		"CGO_ENABLED="+cgo,
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.exports == nil {
+		g.exports = make(map[string]string)
+		g.errs = make(map[string]error)
+	}
+	if err != nil {
+		g.errs[path] = fmt.Errorf("go list -export %s: %v: %s", path, err, bytes.TrimSpace(stderr.Bytes()))
+		return g.errs[path]
+	}
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		fields := strings.SplitN(sc.Text(), "\t", 2)
+		if len(fields) == 2 && fields[1] != "" {
+			g.exports[fields[0]] = fields[1]
+		}
+	}
+	if _, ok := g.exports[path]; !ok {
+		g.errs[path] = fmt.Errorf("go list -export %s: no export data", path)
+		return g.errs[path]
+	}
+	return nil
+}
+
+// sourceImporter type checks dependencies from source, ignoring the
+// bodies of their functions.
+type sourceImporter struct {
+	ctx      *build.Context
+	fset     *token.FileSet
+	sizes    types.Sizes
+	packages map[string]*types.Package // by import path; nil while being checked
+}
+
+func (s *sourceImporter) Import(path string) (*types.Package, error) {
+	return s.ImportFrom(path, "", 0)
+}
+
+func (s *sourceImporter) ImportFrom(path, dir string, _ types.ImportMode) (*types.Package, error) {
+	if path == "unsafe" {
+		return types.Unsafe, nil
+	}
+	bp, err := findPackage(s.ctx, path, dir, 0)
+	if err != nil {
+		return nil, err
+	}
+	if pkg, ok := s.packages[bp.ImportPath]; ok {
+		if pkg == nil {
+			return nil, fmt.Errorf("import cycle through package %s", bp.ImportPath)
+		}
+		return pkg, nil
+	}
+
+	s.packages[bp.ImportPath] = nil
+	var files []*ast.File
+	for _, name := range append(bp.GoFiles, bp.CgoFiles...) {
+		f, err := parser.ParseFile(s.fset, filepath.Join(bp.Dir, name), nil, 0)
+		if err != nil {
+			delete(s.packages, bp.ImportPath)
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	var firstErr error
+	config := &types.Config{
+		Error: func(err error) {
+			if firstErr == nil {
+				firstErr = err
+			}
+		},
+		Importer:         s,
+		Sizes:            s.sizes,
+		FakeImportC:      true,
+		IgnoreFuncBodies: true,
+	}
+	pkg, _ := config.Check(bp.ImportPath, s.fset, files, nil)
+	s.packages[bp.ImportPath] = pkg
+	if firstErr != nil {
+		return pkg, fmt.Errorf("type checking package %s failed: %v", bp.ImportPath, firstErr)
+	}
+	return pkg, nil
+}
+
+// gccgoImporter reads gccgo export data from the compiler's search
+// paths and from $GOPATH/pkg.
+type gccgoImporter struct {
+	ctx     *build.Context
+	fset    *token.FileSet
+	imports map[string]*types.Package
+
+	once sync.Once
+	dirs []string // search paths
+}
+
+func (g *gccgoImporter) Import(path string) (*types.Package, error) {
+	return g.ImportFrom(path, "", 0)
+}
+
+func (g *gccgoImporter) ImportFrom(path, _ string, _ types.ImportMode) (*types.Package, error) {
+	if path == "unsafe" {
+		return types.Unsafe, nil
+	}
+	if pkg, ok := g.imports[path]; ok && pkg.Complete() {
+		return pkg, nil
+	}
+	g.once.Do(g.init)
+
+	dir, base := filepath.Split(filepath.FromSlash(path))
+	for _, d := range g.dirs {
+		for _, name := range []string{base + ".gox", "lib" + base + ".so", "lib" + base + ".a", base + ".o"} {
+			f, err := os.Open(filepath.Join(d, dir, name))
+			if err != nil {
+				continue
+			}
+			pkg, err := g.read(f, path)
+			f.Close()
+			return pkg, err
+		}
+	}
+	return nil, fmt.Errorf("can't find gccgo export data for %s", path)
+}
+
+func (g *gccgoImporter) read(f *os.File, path string) (*types.Package, error) {
+	r, err := gccgoexportdata.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("reading export data for %s: %v", path, err)
+	}
+	return gccgoexportdata.Read(r, g.fset, g.imports, path)
+}
+
+func (g *gccgoImporter) init() {
+	gccgo := os.Getenv("GCCGO")
+	if gccgo == "" {
+		gccgo = "gccgo"
+	}
+	if _, _, dirs, err := gccgoexportdata.CompilerInfo(gccgo); err == nil {
+		g.dirs = append(g.dirs, dirs...)
+	}
+	for _, d := range filepath.SplitList(g.ctx.GOPATH) {
+		g.dirs = append(g.dirs, filepath.Join(d, "pkg", "gccgo_"+g.ctx.GOOS+"_"+g.ctx.GOARCH))
+	}
+}
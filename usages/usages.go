@@ -8,9 +8,10 @@ import (
 	"go/parser"
 	"go/token"
 	"go/types"
+	pathpkg "path"
 	"sort"
-
-	"golang.org/x/tools/go/gcexportdata"
+	"strconv"
+	"strings"
 )
 
 // Function input kinds.
@@ -30,6 +31,19 @@ type Result struct {
 
 	FuncPosition token.Position // position of function
 	FuncName     string         // name of function, or empty string if function literal
+	Func         ast.Node       // either *ast.FuncDecl or *ast.FuncLit
+	CgoExport    bool           // function has a cgo //export directive, which constrains its signature
+
+	// Dead is set if Config.DeadCode is set and the receiver/param has
+	// uses, but all of them are in unreachable code or in computations
+	// whose results are unused. A use assigned to the blank identifier
+	// is taken to be deliberate.
+	Dead bool
+
+	// DeadSkipped is set if Config.DeadCode is set but SSA form could
+	// not be built for the package, such as for generic code, so that
+	// Dead is never set.
+	DeadSkipped bool
 }
 
 type file struct {
@@ -42,9 +56,41 @@ type target struct {
 	funcInput    funcInput
 	funcPosition token.Position
 	funcName     string
+	fn           ast.Node
+	cgoExport    bool
 	uses         []*ast.Ident
 }
 
+// A Config configures how packages are type checked by Find.
+type Config struct {
+	// Fset is the file set used to parse the files. If nil, Find uses
+	// a new file set.
+	Fset *token.FileSet
+
+	// Importer imports the dependencies of the packages. It must record
+	// positions in Fset. If nil, Find uses NewImporter(ImporterAuto, nil, fset).
+	Importer types.Importer
+
+	// ImportPath is the import path of the package under test, if known.
+	// As with go test, the files of a package pkg (including its internal
+	// test files) are type checked together, and an external test package
+	// pkg_test is type checked afterwards, importing pkg by ImportPath.
+	// If ImportPath is empty, an import in pkg_test whose last element
+	// is pkg is taken to be the package under test.
+	ImportPath string
+
+	// DeadCode, if set, makes Find build SSA form for each package that
+	// type checks without errors, to set Result.Dead.
+	DeadCode bool
+}
+
+// Find finds the usages of the receivers and params of functions
+// in the supplied files, using a zero Config.
+func Find(files map[string][]byte) (results map[string][]Result, typeInfo map[string]*types.Info,
+	warns map[string][]error, err error) {
+	return (&Config{}).Find(files)
+}
+
 // Find finds the usages of the receivers and params of functions
 // in the supplied files. Files is a map from the file's path to its contents.
 // The results is a map from the package name to the usage results.
@@ -57,15 +103,18 @@ type target struct {
 //   Invariant: len(results) == number of packages.
 //   Invariant: len(results[key]) == number of receivers/params, except blank
 //              identifiers or unnamed receivers/params.
-func Find(files map[string][]byte) (results map[string][]Result, typeInfo map[string]*types.Info,
+func (c *Config) Find(files map[string][]byte) (results map[string][]Result, typeInfo map[string]*types.Info,
 	warns map[string][]error, err error) {
-	fset := token.NewFileSet()
+	fset := c.Fset
+	if fset == nil {
+		fset = token.NewFileSet()
+	}
 	uniquePkgNames := make(map[string]struct{})
 	var parsedFiles []file
 
 	// Parse the files; determine the packages that are present.
 	for path, content := range files {
-		f, err := parser.ParseFile(fset, path, content, 0)
+		f, err := parser.ParseFile(fset, path, content, parser.ParseComments)
 		if err != nil {
 			return nil, nil, warns, err
 		}
@@ -81,18 +130,41 @@ func Find(files map[string][]byte) (results map[string][]Result, typeInfo map[st
 	// path. We'll be type checking per package anyway.
 	// If the type checker errors out on the multiple packages, we'll warn
 	// them, but it shouldn't affect what we're doing.
-	importer := gcexportdata.NewImporter(fset, make(map[string]*types.Package))
+	importer := &testImporter{Importer: c.Importer}
+	if importer.Importer == nil {
+		importer.Importer = NewImporter(ImporterAuto, nil, fset)
+	}
 	config := &types.Config{
 		Error:    func(error) {}, // keep going on error
 		Importer: importer,
+		// References to C.foo in cgo files can't be resolved without
+		// running cgo; accept them and keep checking the rest.
+		FakeImportC: true,
 	}
 
 	// Map from package to type info for that package.
 	pkgInfos := make(map[string]*types.Info)
 	warns = make(map[string][]error)
 
-	// Check each package, and record the type info.
+	// Check each package, and record the type info. External test
+	// packages are checked last, so that they can import the package
+	// under test.
+	var pkgOrder []string
 	for pkg := range uniquePkgNames {
+		pkgOrder = append(pkgOrder, pkg)
+	}
+	sort.Slice(pkgOrder, func(i, j int) bool {
+		xi, xj := isXTest(pkgOrder[i], uniquePkgNames), isXTest(pkgOrder[j], uniquePkgNames)
+		if xi != xj {
+			return !xi
+		}
+		return pkgOrder[i] < pkgOrder[j]
+	})
+	checked := make(map[string]*types.Package)
+	dead := make(map[string]map[types.Object]bool) // dead inputs by package, if c.DeadCode
+	deadSkipped := make(map[string]bool)           // packages SSA form couldn't be built for
+
+	for _, pkg := range pkgOrder {
 		var astFiles []*ast.File
 		for _, f := range parsedFiles {
 			if f.pkg == pkg {
@@ -100,15 +172,35 @@ func Find(files map[string][]byte) (results map[string][]Result, typeInfo map[st
 			}
 		}
 		info := &types.Info{
-			Types:  make(map[ast.Expr]types.TypeAndValue),
-			Defs:   make(map[*ast.Ident]types.Object),
-			Uses:   make(map[*ast.Ident]types.Object),
-			Scopes: make(map[ast.Node]*types.Scope),
+			Types:      make(map[ast.Expr]types.TypeAndValue),
+			Defs:       make(map[*ast.Ident]types.Object),
+			Uses:       make(map[*ast.Ident]types.Object),
+			Implicits:  make(map[ast.Node]types.Object),
+			Scopes:     make(map[ast.Node]*types.Scope),
+			Selections: make(map[*ast.SelectorExpr]*types.Selection),
+		}
+		path := ""
+		importer.path, importer.pkg = "", nil
+		if isXTest(pkg, uniquePkgNames) {
+			under := strings.TrimSuffix(pkg, "_test")
+			importer.path, importer.pkg = c.ImportPath, checked[under]
+			if c.ImportPath == "" {
+				importer.path = guessImportPath(astFiles, under)
+			}
+			if c.ImportPath != "" {
+				path = c.ImportPath + "_test"
+			}
+		} else {
+			path = c.ImportPath
 		}
-		_, err := config.Check("", fset, astFiles, info)
+		tpkg, err := config.Check(path, fset, astFiles, info)
 		if err != nil {
 			warns[pkg] = append(warns[pkg], err)
+		} else if c.DeadCode {
+			dead[pkg] = deadInputs(fset, tpkg, astFiles, info)
+			deadSkipped[pkg] = dead[pkg] == nil
 		}
+		checked[pkg] = tpkg
 
 		// Record the info for the package.
 		pkgInfos[pkg] = info
@@ -140,6 +232,7 @@ func Find(files map[string][]byte) (results map[string][]Result, typeInfo map[st
 			var inp []funcInput
 			var funcPosition token.Position
 			var funcName string
+			var cgoExport bool
 
 			// Functions can either be function declarations (top-level)
 			// or function literals.
@@ -148,6 +241,7 @@ func Find(files map[string][]byte) (results map[string][]Result, typeInfo map[st
 				inp = inputs(c.Recv, c.Type.Params)
 				funcPosition = fset.Position(c.Name.Pos())
 				funcName = c.Name.Name
+				cgoExport = hasCgoExport(c.Doc)
 			case *ast.FuncLit:
 				inp = inputs(nil, c.Type.Params)
 				funcPosition = fset.Position(c.Pos())
@@ -166,6 +260,8 @@ func Find(files map[string][]byte) (results map[string][]Result, typeInfo map[st
 					funcInput:    in,
 					funcPosition: funcPosition,
 					funcName:     funcName,
+					fn:           n,
+					cgoExport:    cgoExport,
 					// uses filled in below
 				}
 			}
@@ -179,13 +275,14 @@ func Find(files map[string][]byte) (results map[string][]Result, typeInfo map[st
 	for pkg := range uniquePkgNames {
 		targets := allTargets[pkg]
 		info := pkgInfos[pkg]
-		results[pkg] = makeResult(targets, info, fset)
+		results[pkg] = makeResult(targets, info, fset, dead[pkg], deadSkipped[pkg])
 	}
 	return results, pkgInfos, warns, nil
 }
 
-// makeResult computes results for a package.
-func makeResult(targets map[token.Position]target, info *types.Info, fset *token.FileSet) []Result {
+// makeResult computes results for a package. dead holds the inputs
+// whose uses are all dead, if known.
+func makeResult(targets map[token.Position]target, info *types.Info, fset *token.FileSet, dead map[types.Object]bool, deadSkipped bool) []Result {
 	var r []Result
 
 	// Mark function receiver/parameter as satisfied.
@@ -218,7 +315,11 @@ func makeResult(targets map[token.Position]target, info *types.Info, fset *token
 		if a.funcPosition.Line > b.funcPosition.Line {
 			return false
 		}
-		return a.funcPosition.Column < b.funcPosition.Column
+		if a.funcPosition.Column != b.funcPosition.Column {
+			return a.funcPosition.Column < b.funcPosition.Column
+		}
+		// Same function; order by the position of the receiver/param.
+		return a.funcInput.pos < b.funcInput.pos
 	})
 
 	for _, t := range sortedTargets {
@@ -230,6 +331,10 @@ func makeResult(targets map[token.Position]target, info *types.Info, fset *token
 			Position:     fset.Position(t.funcInput.pos),
 			FuncPosition: t.funcPosition,
 			FuncName:     t.funcName,
+			Func:         t.fn,
+			CgoExport:    t.cgoExport,
+			Dead:         len(t.uses) > 0 && dead[info.Defs[t.funcInput.ident]] && !blankAssigned(t.fn, t.uses, info),
+			DeadSkipped:  deadSkipped,
 		})
 	}
 
@@ -272,6 +377,72 @@ func inputs(recv, params *ast.FieldList) []funcInput {
 	return inp
 }
 
+// isXTest reports whether pkg is the name of an external test package
+// for another package in pkgs.
+func isXTest(pkg string, pkgs map[string]struct{}) bool {
+	if !strings.HasSuffix(pkg, "_test") {
+		return false
+	}
+	_, ok := pkgs[strings.TrimSuffix(pkg, "_test")]
+	return ok
+}
+
+// guessImportPath returns the path of an import in the external test
+// files whose last element is the name of the package under test.
+func guessImportPath(files []*ast.File, under string) string {
+	for _, f := range files {
+		for _, imp := range f.Imports {
+			p, err := strconv.Unquote(imp.Path.Value)
+			if err == nil && pathpkg.Base(p) == under {
+				return p
+			}
+		}
+	}
+	return ""
+}
+
+// testImporter imports the package under test from the package
+// already type checked by Find, and other packages using Importer.
+type testImporter struct {
+	types.Importer
+	path string         // import path of the package under test
+	pkg  *types.Package // the package under test, or nil
+}
+
+func (i *testImporter) Import(path string) (*types.Package, error) {
+	if i.pkg != nil && path == i.path {
+		return i.pkg, nil
+	}
+	return i.Importer.Import(path)
+}
+
+// ImportFrom is like Import, but passes dir on to Importer if it is a
+// types.ImporterFrom, so that vendored and module dependencies are
+// resolved relative to the importing package.
+func (i *testImporter) ImportFrom(path, dir string, mode types.ImportMode) (*types.Package, error) {
+	if i.pkg != nil && path == i.path {
+		return i.pkg, nil
+	}
+	if from, ok := i.Importer.(types.ImporterFrom); ok {
+		return from.ImportFrom(path, dir, mode)
+	}
+	return i.Importer.Import(path)
+}
+
+// hasCgoExport reports whether the function doc has a cgo
+// "//export Name" directive.
+func hasCgoExport(doc *ast.CommentGroup) bool {
+	if doc == nil {
+		return false
+	}
+	for _, c := range doc.List {
+		if strings.HasPrefix(c.Text, "//export ") {
+			return true
+		}
+	}
+	return false
+}
+
 func isBlankIdent(name *ast.Ident) bool {
 	return name.Name == "_"
 }
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/token"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nishanths/unusedargs/usages"
+)
+
+// A usesQuery identifies the function whose uses are printed by the
+// uses subcommand: either a package and the name of a function or
+// method in it, or a line of a file.
+type usesQuery struct {
+	pkg  string // import path or directory
+	name string // Func, or Type.Method
+	file string
+	line int
+}
+
+// parseUsesQuery parses a query of the form pkg.Func, pkg.Type.Method,
+// or file.go:line. Since the last element of an import path may itself
+// contain dots, as in gopkg.in/yaml.v2, a query such as a/b.c.d is
+// ambiguous; the candidate readings are returned in the order they
+// should be tried: pkg.Func first, then pkg.Type.Method.
+func parseUsesQuery(q string) ([]usesQuery, error) {
+	if i := strings.LastIndex(q, ":"); i >= 0 && strings.HasSuffix(q[:i], ".go") {
+		line, err := strconv.Atoi(q[i+1:])
+		if err != nil || line < 1 {
+			return nil, fmt.Errorf("bad line number in %s", q)
+		}
+		return []usesQuery{{file: q[:i], line: line}}, nil
+	}
+	slash := strings.LastIndex(q, "/")
+	last := strings.LastIndex(q, ".")
+	if last <= slash+1 || last == len(q)-1 {
+		return nil, fmt.Errorf("bad function %s: want pkg.Func, pkg.Type.Method, or file.go:line", q)
+	}
+	qs := []usesQuery{{pkg: q[:last], name: q[last+1:]}}
+	if prev := strings.LastIndex(q[:last], "."); prev > slash+1 && prev < last-1 {
+		qs = append(qs, usesQuery{pkg: q[:prev], name: q[prev+1:]})
+	}
+	return qs, nil
+}
+
+// importUsesQuery returns the first of the candidate queries whose
+// package can be imported, and the package. If none can be, the error
+// importing the first candidate's package is returned.
+func importUsesQuery(qs []usesQuery) (usesQuery, *build.Package, error) {
+	var firstErr error
+	for _, q := range qs {
+		var pkg *build.Package
+		var err error
+		switch {
+		case q.file != "":
+			pkg, err = buildContext.ImportDir(filepath.Dir(q.file), 0)
+		case build.IsLocalImport(q.pkg):
+			pkg, err = buildContext.ImportDir(q.pkg, 0)
+		default:
+			pkg, err = buildContext.Import(q.pkg, ".", 0)
+		}
+		if err == nil {
+			return q, pkg, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return usesQuery{}, nil, firstErr
+}
+
+// printUses prints each receiver and param of the functions matching
+// the query, with the positions of their uses. For a file and line,
+// the innermost function containing the line is printed.
+func printUses(w io.Writer, query string) error {
+	qs, err := parseUsesQuery(query)
+	if err != nil {
+		return err
+	}
+	q, pkg, err := importUsesQuery(qs)
+	if err != nil {
+		return err
+	}
+	contents, _, err := readFiles(pkgFiles(pkg))
+	if err != nil {
+		return err
+	}
+	fset := token.NewFileSet()
+	config := &usages.Config{
+		Fset:       fset,
+		Importer:   usages.NewImporter(importerName, &buildContext, fset),
+		ImportPath: importPath(pkg),
+	}
+	results, _, _, err := config.Find(contents)
+	if err != nil {
+		return err
+	}
+
+	// Group the results by function, in file order.
+	var funcs [][]usages.Result
+	var pkgOrder []string
+	for p := range results {
+		pkgOrder = append(pkgOrder, p)
+	}
+	sort.Strings(pkgOrder)
+	for _, p := range pkgOrder {
+		for i, r := range results[p] {
+			if i == 0 || r.Func != results[p][i-1].Func {
+				funcs = append(funcs, nil)
+			}
+			funcs[len(funcs)-1] = append(funcs[len(funcs)-1], r)
+		}
+	}
+
+	var matches [][]usages.Result
+	if q.file != "" {
+		// Find the innermost function containing the line.
+		var inner []usages.Result
+		for _, fn := range funcs {
+			r := fn[0]
+			if absPath(r.FuncPosition.Filename) != absPath(q.file) {
+				continue
+			}
+			start, end := fset.Position(r.Func.Pos()).Line, fset.Position(r.Func.End()).Line
+			if start <= q.line && q.line <= end && (inner == nil || r.Func.Pos() > inner[0].Func.Pos()) {
+				inner = fn
+			}
+		}
+		if inner != nil {
+			matches = append(matches, inner)
+		}
+	} else {
+		for _, fn := range funcs {
+			if decl, ok := fn[0].Func.(*ast.FuncDecl); ok && qualifiedName(decl) == q.name {
+				matches = append(matches, fn)
+			}
+		}
+	}
+	if len(matches) == 0 {
+		return fmt.Errorf("no function with named receivers or params found for %s", query)
+	}
+
+	for _, fn := range matches {
+		name := "func"
+		if decl, ok := fn[0].Func.(*ast.FuncDecl); ok {
+			name = qualifiedName(decl)
+		}
+		fmt.Fprintf(w, "%s: %s\n", fn[0].FuncPosition, name)
+		for _, r := range fn {
+			uses := make([]token.Position, len(r.Uses))
+			for i, id := range r.Uses {
+				uses[i] = fset.Position(id.Pos())
+			}
+			sort.Slice(uses, func(i, j int) bool { return uses[i].Offset < uses[j].Offset })
+			word := "uses"
+			if len(uses) == 1 {
+				word = "use"
+			}
+			fmt.Fprintf(w, "\t%s %s: %d %s\n", r.Kind, r.Ident.Name, len(uses), word)
+			for _, u := range uses {
+				fmt.Fprintf(w, "\t\t%s\n", u)
+			}
+		}
+	}
+	return nil
+}
+
+// qualifiedName returns the name of the function, or Type.Method for
+// a method.
+func qualifiedName(decl *ast.FuncDecl) string {
+	if recv := recvTypeName(decl); recv != "" {
+		return recv + "." + decl.Name.Name
+	}
+	return decl.Name.Name
+}
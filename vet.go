@@ -0,0 +1,253 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/importer"
+	"go/token"
+	"go/types"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// This file implements the protocol go vet uses to run the command as
+// a -vettool, as described in golang.org/x/tools/go/analysis/unitchecker.
+// go vet loads and builds the packages; for each package, it writes a
+// JSON config file describing the package and its dependencies' export
+// data, and runs the command with the config file as its argument.
+
+// vetConfig is the JSON config file written by go vet.
+type vetConfig struct {
+	ID                        string // e.g. "fmt [fmt.test]"
+	Compiler                  string
+	Dir                       string
+	ImportPath                string
+	GoFiles                   []string
+	NonGoFiles                []string
+	ImportMap                 map[string]string // maps import path to package path
+	PackageFile               map[string]string // maps package path to export data file
+	Standard                  map[string]bool
+	PackageVetx               map[string]string // maps package path to vetx file
+	VetxOnly                  bool              // only compute facts for dependents
+	VetxOutput                string            // where to write facts
+	Stdout                    string            // where to write standard output, if set
+	SucceedOnTypecheckFailure bool
+}
+
+// printVetVersion prints the version line go vet expects from -V=full.
+// go vet uses it to decide whether cached results are still valid, so
+// for development builds it identifies the executable by its hash.
+func printVetVersion(w io.Writer) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(exe)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s version devel comments-go-here buildID=%02x\n", filepath.Base(os.Args[0]), h.Sum(nil))
+	return err
+}
+
+// nonVetFlags are the flags that have no meaning when the command is
+// run by go vet, which loads the packages itself and runs the command
+// on one package at a time.
+var nonVetFlags = map[string]bool{
+	"V": true, "flags": true, // the queries go vet makes
+	"j": true, "cache": true, "watch": true, "format": true, "stats": true, "config": true,
+	"importer": true, "staged": true, "stdin": true, "stdin-filename": true, "overlay": true, "fix": true,
+	"interfaces": true, "functypes": true, // need every package at once
+}
+
+// printVetFlags describes the flags in fs that apply under go vet as
+// JSON, so that go vet can accept them on its command line and pass
+// them on.
+func printVetFlags(w io.Writer, fs *flag.FlagSet) error {
+	type jsonFlag struct {
+		Name  string
+		Bool  bool
+		Usage string
+	}
+	flags := []jsonFlag{}
+	fs.VisitAll(func(f *flag.Flag) {
+		if nonVetFlags[f.Name] {
+			return
+		}
+		b, ok := f.Value.(interface{ IsBoolFlag() bool })
+		flags = append(flags, jsonFlag{Name: f.Name, Bool: ok && b.IsBoolFlag(), Usage: flagUsage(f.Name)})
+	})
+	b, err := json.MarshalIndent(flags, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
+
+// flagUsage returns the description of the flag in help, on one line.
+func flagUsage(name string) string {
+	var desc []string
+	for _, line := range strings.Split(help, "\n") {
+		if strings.HasPrefix(line, "  -") {
+			if len(desc) > 0 {
+				break
+			}
+			if f := strings.Fields(line); f[0] == "-"+name {
+				desc = f[1:]
+			}
+			continue
+		}
+		if len(desc) > 0 {
+			if strings.TrimSpace(line) == "" {
+				break
+			}
+			desc = append(desc, strings.Fields(line)...)
+		}
+	}
+	return strings.Join(desc, " ")
+}
+
+// runVet checks the package described by the go vet config file and
+// returns the exit code. Diagnostics are written to stderr, or as JSON
+// to stdout if -json is set. Newer versions of go vet always set -json,
+// and ask for stdout to be written to a file instead.
+func runVet(cfgFile string, stdout, stderr io.Writer) int {
+	if checkInterfaces || checkFuncTypes || fixChanDir {
+		fmt.Fprintln(stderr, "-interfaces, -functypes, and -fix are not supported with go vet, which checks one package at a time")
+		return 1
+	}
+	b, err := ioutil.ReadFile(cfgFile)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	var cfg vetConfig
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		fmt.Fprintf(stderr, "cannot decode JSON config file %s: %v\n", cfgFile, err)
+		return 1
+	}
+
+	// The command computes no facts, but go vet expects the file.
+	if cfg.VetxOutput != "" {
+		if err := ioutil.WriteFile(cfg.VetxOutput, nil, 0666); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+	}
+	if cfg.VetxOnly {
+		return 0
+	}
+
+	contents, _, err := readFiles(cfg.GoFiles)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	newImporter := func(fset *token.FileSet) types.Importer {
+		return vetImporter{
+			cfg: &cfg,
+			imp: importer.ForCompiler(fset, cfg.Compiler, func(path string) (io.ReadCloser, error) {
+				file, ok := cfg.PackageFile[path]
+				if !ok {
+					return nil, fmt.Errorf("no export data for %q", path)
+				}
+				return os.Open(file)
+			}),
+		}
+	}
+	rep := analyse(cfg.ImportPath, buildContext.GOARCH, newSharedImporter(newImporter), contents, nil)
+	if rep.Err != nil {
+		fmt.Fprintln(stderr, rep.Err)
+		return 1
+	}
+	if len(rep.Partial) > 0 {
+		if cfg.SucceedOnTypecheckFailure {
+			return 0
+		}
+		for _, pkg := range rep.Partial {
+			fmt.Fprintf(stderr, "failed to type check package %s: results may be partial\n", pkg)
+		}
+	}
+	for _, pkg := range rep.NoDead {
+		fmt.Fprintf(stderr, "-deadcode skipped for package %s: it can't be built in SSA form\n", pkg)
+	}
+
+	var findings []finding
+	for _, f := range rep.Findings {
+		if matchesFilters(f) {
+			findings = append(findings, f)
+		}
+	}
+
+	if vetJSON {
+		// The format is a tree: package ID -> analyzer name -> diagnostics.
+		type jsonDiagnostic struct {
+			Posn    string `json:"posn"`
+			Message string `json:"message"`
+		}
+		diags := []jsonDiagnostic{}
+		for _, f := range findings {
+			diags = append(diags, jsonDiagnostic{
+				Posn:    f.FuncPosition.String(),
+				Message: f.message(),
+			})
+		}
+		tree := map[string]map[string][]jsonDiagnostic{}
+		if len(diags) > 0 {
+			tree[cfg.ID] = map[string][]jsonDiagnostic{"unusedargs": diags}
+		}
+		b, err := json.MarshalIndent(tree, "", "\t")
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		b = append(b, '\n')
+		if cfg.Stdout != "" {
+			err = ioutil.WriteFile(cfg.Stdout, b, 0666)
+		} else {
+			_, err = stdout.Write(b)
+		}
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		return 0
+	}
+
+	for _, f := range findings {
+		fmt.Fprintln(stderr, f)
+	}
+	if len(findings) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// vetImporter imports packages from the export data files listed in
+// the go vet config.
+type vetImporter struct {
+	cfg *vetConfig
+	imp types.Importer
+}
+
+func (v vetImporter) Import(importPath string) (*types.Package, error) {
+	path, ok := v.cfg.ImportMap[importPath]
+	if !ok {
+		path = importPath
+	}
+	if path == "unsafe" {
+		return types.Unsafe, nil
+	}
+	return v.imp.Import(path)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunVet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "unusedargs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := vetConfig{
+		ID:         "example.com/pkg2",
+		Compiler:   "gc",
+		ImportPath: "example.com/pkg2",
+		GoFiles:    []string{"testdata/pkg2/pkg2.go"},
+		VetxOutput: filepath.Join(dir, "vet.out"),
+	}
+	b, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfgFile := filepath.Join(dir, "vet.cfg")
+	if err := ioutil.WriteFile(cfgFile, b, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := runVet(cfgFile, &stdout, &stderr); code != 1 {
+		t.Errorf("want exit code 1, got %d", code)
+	}
+	if want := "testdata/pkg2/pkg2.go:3:6: qux has unused param x\n"; stderr.String() != want {
+		t.Errorf("want stderr %q, got %q", want, stderr.String())
+	}
+	if _, err := os.Stat(cfg.VetxOutput); err != nil {
+		t.Errorf("vetx output not written: %v", err)
+	}
+
+	defer func(b bool) { vetJSON = b }(vetJSON)
+	vetJSON = true
+	stdout.Reset()
+	stderr.Reset()
+	if code := runVet(cfgFile, &stdout, &stderr); code != 0 {
+		t.Errorf("-json: want exit code 0, got %d: %s", code, stderr.String())
+	}
+	var tree map[string]map[string][]struct {
+		Posn    string `json:"posn"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &tree); err != nil {
+		t.Fatal(err)
+	}
+	diags := tree[cfg.ID]["unusedargs"]
+	if len(diags) != 1 || diags[0].Posn != "testdata/pkg2/pkg2.go:3:6" || diags[0].Message != "qux has unused param x" {
+		t.Errorf("unexpected JSON diagnostics: %s", stdout.String())
+	}
+}
+
+func TestVetFlags(t *testing.T) {
+	fs := flag.NewFlagSet("unusedargs", flag.ContinueOnError)
+	defineFlags(fs)
+	var buf bytes.Buffer
+	if err := printVetFlags(&buf, fs); err != nil {
+		t.Fatal(err)
+	}
+	var flags []struct {
+		Name  string
+		Bool  bool
+		Usage string
+	}
+	if err := json.Unmarshal(buf.Bytes(), &flags); err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]bool)
+	for _, f := range flags {
+		got[f.Name] = true
+		if f.Usage == "" {
+			t.Errorf("flag -%s has no usage", f.Name)
+		}
+		if f.Name == "json" && (!f.Bool || f.Usage != "With go vet, print diagnostics as JSON (default false).") {
+			t.Errorf("unexpected -json flag %+v", f)
+		}
+	}
+	for _, name := range []string{"json", "kind", "deadcode", "narrow", "fields", "chandir", "pointers", "logging"} {
+		if !got[name] {
+			t.Errorf("flag -%s missing", name)
+		}
+	}
+	for _, name := range []string{"importer", "interfaces", "functypes", "fix", "j"} {
+		if got[name] {
+			t.Errorf("flag -%s listed", name)
+		}
+	}
+}
+
+func TestRunVetUnsupported(t *testing.T) {
+	defer func(b bool) { checkInterfaces = b }(checkInterfaces)
+	checkInterfaces = true
+	var stdout, stderr bytes.Buffer
+	if code := runVet("nonexistent.cfg", &stdout, &stderr); code != 1 || !strings.Contains(stderr.String(), "not supported with go vet") {
+		t.Errorf("want exit code 1 and an error, got %d: %s", code, stderr.String())
+	}
+}
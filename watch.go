@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"go/build"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// watchInterval is how often watch polls for changes.
+const watchInterval = 500 * time.Millisecond
+
+// watch runs the tasks and prints their findings. It then polls the .go
+// files in each task's watched paths and in the local packages its
+// packages import, and whenever they change re-runs the affected tasks
+// and prints the refreshed findings of all tasks. paths[i] lists the
+// directories and files watched for tasks[i].
+// watch never returns.
+func watch(tasks []task, paths [][]string, interval time.Duration) {
+	states := make([]watchState, len(tasks))
+	for {
+		rerunStale(tasks, paths, states)
+		time.Sleep(interval)
+	}
+}
+
+// A watchState is what watch knows of a task from its last run.
+type watchState struct {
+	report    *report
+	snapshot  string   // of the watched paths when it was run
+	deps      []string // directories of the local packages it imports
+	depsState string   // snapshot of deps when it was run
+}
+
+// rerunStale runs the tasks that haven't been run, or whose watched
+// paths or local dependencies have changed since, and if there were
+// any, prints the findings and summary of all tasks. states[i] holds
+// what is known of tasks[i] from its last run.
+func rerunStale(tasks []task, paths [][]string, states []watchState) {
+	var stale []task
+	var staleIdx []int
+	for i := range tasks {
+		st := &states[i]
+		s, d := snapshot(paths[i]), snapshot(st.deps)
+		if st.report != nil && s == st.snapshot && d == st.depsState {
+			continue
+		}
+		st.snapshot, st.depsState = s, d
+		stale = append(stale, tasks[i])
+		staleIdx = append(staleIdx, i)
+	}
+	if len(stale) == 0 {
+		return
+	}
+
+	n := 0
+	resetImporters()
+	resetDepIDs()
+	runTasks(stale, jobs, func(rep *report) {
+		st := &states[staleIdx[n]]
+		st.report = rep
+		if deps := reportDeps(rep); !reflect.DeepEqual(deps, st.deps) {
+			st.deps, st.depsState = deps, snapshot(deps)
+		}
+		n++
+	})
+	fmt.Fprintf(output, "--- %s\n", time.Now().Format("15:04:05"))
+	for _, st := range states {
+		handleReport(st.report)
+	}
+	if checkInterfaces || checkFuncTypes {
+		reportAbstract()
+	}
+	if err := printSummary(); err != nil {
+		log.Fatal(err)
+	}
+	// The next run prints its own summary.
+	summary.Findings, summary.Stats = nil, nil
+}
+
+// reportDeps returns the sorted directories of the local packages
+// imported by the packages whose files were checked for rep.
+func reportDeps(rep *report) []string {
+	seen := make(map[string]bool)
+	var deps []string
+	for _, name := range rep.Files {
+		dir := filepath.Dir(name)
+		if seen[dir] {
+			continue
+		}
+		seen[dir] = true
+		for _, d := range localDeps(&buildContext, dir) {
+			if !seen[d] {
+				seen[d] = true
+				deps = append(deps, d)
+			}
+		}
+	}
+	sort.Strings(deps)
+	return deps
+}
+
+// localDeps returns the directories of the packages imported, directly
+// or indirectly, by the package in dir that are in the same module as
+// it, or if it isn't in a module, in the same GOPATH workspace.
+func localDeps(ctx *build.Context, dir string) []string {
+	pkg, err := ctx.ImportDir(dir, 0)
+	if err != nil {
+		return nil
+	}
+	var mod string
+	if root := moduleRoot(pkg.Dir); root != "" {
+		b, err := ioutil.ReadFile(filepath.Join(root, "go.mod"))
+		if err != nil {
+			return nil
+		}
+		mod = modulePath(b)
+	}
+
+	seen := map[string]bool{pkg.Dir: true}
+	var dirs []string
+	var visit func(p *build.Package, paths []string)
+	visit = func(p *build.Package, paths []string) {
+		for _, path := range paths {
+			if mod != "" && path != mod && !strings.HasPrefix(path, mod+"/") {
+				continue // not in the module; don't run the go command to find it
+			}
+			dep, err := importFromDisk(ctx, path, p.Dir)
+			if err != nil || dep.Goroot || seen[dep.Dir] || mod == "" && dep.Root != pkg.Root {
+				continue
+			}
+			seen[dep.Dir] = true
+			dirs = append(dirs, dep.Dir)
+			visit(dep, dep.Imports)
+		}
+	}
+	visit(pkg, imports(pkg))
+	return dirs
+}
+
+// snapshot returns a string describing the names, sizes, and
+// modification times of the .go files in paths. Paths can be
+// directories or files.
+func snapshot(paths []string) string {
+	var entries []string
+	add := func(name string, fi os.FileInfo) {
+		entries = append(entries, fmt.Sprintf("%s %d %d", name, fi.Size(), fi.ModTime().UnixNano()))
+	}
+	for _, p := range paths {
+		fi, err := os.Stat(p)
+		if err != nil {
+			continue
+		}
+		if !fi.IsDir() {
+			add(p, fi)
+			continue
+		}
+		infos, err := ioutil.ReadDir(p)
+		if err != nil {
+			continue
+		}
+		for _, fi := range infos {
+			if !fi.IsDir() && strings.HasSuffix(fi.Name(), ".go") {
+				add(filepath.Join(p, fi.Name()), fi)
+			}
+		}
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, "\n")
+}
+
+// pkgDir returns the directory of the package with the import path,
+// or the empty string if it cannot be found.
+func pkgDir(path string) string {
+	pkg, err := buildContext.Import(path, ".", build.FindOnly)
+	if err != nil {
+		return ""
+	}
+	return pkg.Dir
+}